@@ -2,8 +2,13 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	_ "time/tzdata" // Timezone database for validating profile timezones on hosts without one
 
 	"github.com/muhammedikinci/yapgan/config"
 	"github.com/muhammedikinci/yapgan/internal/auth"
@@ -12,6 +17,9 @@ import (
 	"github.com/muhammedikinci/yapgan/internal/server"
 	"github.com/muhammedikinci/yapgan/pkg/database"
 	"github.com/muhammedikinci/yapgan/pkg/embedding"
+	"github.com/muhammedikinci/yapgan/pkg/jwtkeys"
+	"github.com/muhammedikinci/yapgan/pkg/mail"
+	"github.com/muhammedikinci/yapgan/pkg/oidc"
 	"github.com/muhammedikinci/yapgan/pkg/qdrant"
 )
 
@@ -37,6 +45,136 @@ func (r *chatNoteRepository) FindByID(
 	}, nil
 }
 
+// notesPreferenceProvider adapts auth.Service preferences to notes.PreferenceProvider
+type notesPreferenceProvider struct {
+	authService *auth.Service
+}
+
+func (p *notesPreferenceProvider) NotePreferences(
+	ctx context.Context,
+	userID string,
+) (*notes.Preferences, error) {
+	prefs, err := p.authService.GetPreferences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	notePrefs := &notes.Preferences{
+		PublicByDefault: prefs.DefaultVisibility != nil && *prefs.DefaultVisibility == auth.VisibilityPublic,
+	}
+	if prefs.PageSize != nil {
+		notePrefs.PageSize = *prefs.PageSize
+	}
+	if prefs.SearchLanguage != nil {
+		notePrefs.SearchLanguage = *prefs.SearchLanguage
+	}
+
+	return notePrefs, nil
+}
+
+// chatPreferenceProvider adapts auth.Service preferences to chat.PreferenceProvider
+type chatPreferenceProvider struct {
+	authService *auth.Service
+	models      []string // Models still allowed by the configuration
+}
+
+func (p *chatPreferenceProvider) ChatModel(ctx context.Context, userID string) (string, error) {
+	prefs, err := p.authService.GetPreferences(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	if prefs.ChatModel == nil || !slices.Contains(p.models, *prefs.ChatModel) {
+		return "", nil
+	}
+
+	return *prefs.ChatModel, nil
+}
+
+// oidcIdentityProvider adapts oidc.Provider to auth.IdentityProvider
+type oidcIdentityProvider struct {
+	provider *oidc.Provider
+}
+
+func (p *oidcIdentityProvider) AuthCodeURL(
+	ctx context.Context,
+	state, nonce, codeChallenge string,
+) (string, error) {
+	return p.provider.AuthCodeURL(ctx, state, nonce, codeChallenge)
+}
+
+func (p *oidcIdentityProvider) Exchange(
+	ctx context.Context,
+	code, codeVerifier, nonce string,
+) (*auth.ExternalIdentity, error) {
+	identity, err := p.provider.Exchange(ctx, code, codeVerifier, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return &auth.ExternalIdentity{
+		Subject:       identity.Subject,
+		Email:         identity.Email,
+		EmailVerified: identity.EmailVerified,
+		Name:          identity.Name,
+	}, nil
+}
+
+// loadKeysets builds the access and refresh token keysets from the configuration
+func loadKeysets(cfg *config.Config) (*jwtkeys.Keyset, *jwtkeys.Keyset, error) {
+	accessKeys, err := jwtkeys.New(cfg.JWT.ActiveKey, jwtKeyConfigs(cfg.JWT.Keys))
+	if err != nil {
+		return nil, nil, fmt.Errorf("access keys: %w", err)
+	}
+
+	refreshKeys, err := jwtkeys.New(cfg.JWT.RefreshActiveKey, jwtKeyConfigs(cfg.JWT.RefreshKeys))
+	if err != nil {
+		return nil, nil, fmt.Errorf("refresh keys: %w", err)
+	}
+
+	return accessKeys, refreshKeys, nil
+}
+
+func jwtKeyConfigs(keys []config.JWTKeyConfig) []jwtkeys.KeyConfig {
+	configs := make([]jwtkeys.KeyConfig, 0, len(keys))
+	for _, key := range keys {
+		configs = append(configs, jwtkeys.KeyConfig{
+			ID:             key.ID,
+			Algorithm:      key.Algorithm,
+			Secret:         key.Secret,
+			PrivateKeyFile: key.PrivateKeyFile,
+			PublicKeyFile:  key.PublicKeyFile,
+		})
+	}
+
+	return configs
+}
+
+// reloadKeysetsOnSignal re-reads the configuration on every SIGHUP and swaps in the
+// new keys. Invalid configurations are logged and the current keys stay in use.
+func reloadKeysetsOnSignal(env string, accessKeys, refreshKeys *jwtkeys.Keyset) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	for range signals {
+		cfg, err := config.Load(env)
+		if err != nil {
+			log.Printf("Failed to reload config: %v", err)
+			continue
+		}
+
+		newAccessKeys, newRefreshKeys, err := loadKeysets(cfg)
+		if err != nil {
+			log.Printf("Failed to reload JWT keys: %v", err)
+			continue
+		}
+
+		accessKeys.Replace(newAccessKeys)
+		refreshKeys.Replace(newRefreshKeys)
+		log.Printf("Reloaded JWT keys (active: %s, refresh active: %s)", cfg.JWT.ActiveKey, cfg.JWT.RefreshActiveKey)
+	}
+}
+
 func main() {
 	if err := run(); err != nil {
 		log.Fatal(err)
@@ -125,17 +263,110 @@ func run() error {
 		)
 	}
 
+	// Initialize mailer
+	var mailer auth.Mailer
+
+	switch cfg.Mail.Provider {
+	case "smtp":
+		mailer = mail.NewSMTPMailer(
+			cfg.Mail.SMTPHost,
+			cfg.Mail.SMTPPort,
+			cfg.Mail.SMTPUsername,
+			cfg.Mail.SMTPPassword,
+			cfg.Mail.From,
+		)
+		log.Printf("Initialized SMTP mailer at %s:%d", cfg.Mail.SMTPHost, cfg.Mail.SMTPPort)
+
+	default:
+		// Log emails instead of sending them (development / offline)
+		mailer = mail.NewLogMailer(cfg.Mail.OutputDir, cfg.Mail.From)
+		log.Println("Initialized log mailer (emails are not delivered)")
+	}
+
+	// Initialize OIDC providers
+	oidcProviders := make([]auth.OIDCProvider, 0, len(cfg.OIDC.Providers))
+	for _, providerCfg := range cfg.OIDC.Providers {
+		provider := oidc.NewProvider(
+			providerCfg.Issuer,
+			providerCfg.ClientID,
+			providerCfg.ClientSecret,
+			providerCfg.RedirectURL,
+			providerCfg.Scopes,
+		)
+		oidcProviders = append(oidcProviders, auth.OIDCProvider{
+			Name:        providerCfg.Name,
+			DisplayName: providerCfg.DisplayName,
+			AllowSignup: providerCfg.AllowSignup,
+			Client:      &oidcIdentityProvider{provider: provider},
+		})
+		log.Printf("Initialized OIDC provider %s (issuer: %s)", providerCfg.Name, providerCfg.Issuer)
+	}
+
+	// Initialize JWT keysets
+	accessKeys, refreshKeys, err := loadKeysets(cfg)
+	if err != nil {
+		log.Fatalf("Failed to load JWT keys: %v", err)
+	}
+	log.Printf("Loaded JWT keys (active: %s, refresh active: %s)", cfg.JWT.ActiveKey, cfg.JWT.RefreshActiveKey)
+
+	// Reload the keys on SIGHUP so they can be rotated without a restart
+	go reloadKeysetsOnSignal(env, accessKeys, refreshKeys)
+
 	// Initialize auth components
 	userRepo := auth.NewPostgresUserRepository(db)
+	refreshTokenRepo := auth.NewPostgresRefreshTokenRepository(db)
+	sessionRepo := auth.NewPostgresSessionRepository(db)
+	accessTokenRepo := auth.NewPostgresAccessTokenRepository(db)
+	passwordResetRepo := auth.NewPostgresPasswordResetRepository(db)
+	verificationRepo := auth.NewPostgresEmailVerificationRepository(db)
+	twoFactorRepo := auth.NewPostgresTwoFactorRepository(db)
+	throttleRepo := auth.NewPostgresThrottleRepository(db)
+	oidcRepo := auth.NewPostgresOIDCRepository(db)
+	inviteRepo := auth.NewPostgresInviteRepository(db)
+	preferencesRepo := auth.NewPostgresPreferencesRepository(db)
 	authService := auth.NewService(
 		userRepo,
-		cfg.JWT.Secret,
-		cfg.JWT.RefreshSecret,
-		cfg.JWT.AccessTokenExpiry,
-		cfg.JWT.RefreshTokenExpiry,
+		refreshTokenRepo,
+		sessionRepo,
+		accessTokenRepo,
+		passwordResetRepo,
+		verificationRepo,
+		twoFactorRepo,
+		throttleRepo,
+		qdrantClient,
+		oidcRepo,
+		oidcProviders,
+		inviteRepo,
+		preferencesRepo,
+		mailer,
+		auth.Config{
+			AppBaseURL:               cfg.App.BaseURL,
+			AccessKeys:               accessKeys,
+			RefreshKeys:              refreshKeys,
+			AccessTokenExpiry:        cfg.JWT.AccessTokenExpiry,
+			RefreshTokenExpiry:       cfg.JWT.RefreshTokenExpiry,
+			PasswordResetExpiry:      cfg.Auth.PasswordResetExpiry,
+			EmailVerificationExpiry:  cfg.Auth.EmailVerificationExpiry,
+			TwoFactorChallengeExpiry: cfg.Auth.TwoFactorChallengeExpiry,
+			RequireVerifiedEmail:     cfg.Auth.RequireVerifiedEmail,
+			LoginLockoutThreshold:    cfg.Auth.LoginLockoutThreshold,
+			IPLockoutThreshold:       cfg.Auth.IPLockoutThreshold,
+			LockoutBaseDuration:      cfg.Auth.LockoutBaseDuration,
+			LockoutMaxDuration:       cfg.Auth.LockoutMaxDuration,
+			RegistrationLimit:        cfg.Auth.RegistrationLimit,
+			RegistrationWindow:       cfg.Auth.RegistrationWindow,
+			RegistrationMode:         cfg.Auth.RegistrationMode,
+			MaxPageSize:              cfg.Pagination.MaxPageSize,
+			SearchLanguages:          notes.SearchLanguages,
+			ChatModels:               cfg.Chat.Models,
+		},
 	)
 	authHandler := auth.NewHandler(authService)
 
+	if err := authService.PromoteAdmins(context.Background(), cfg.Auth.AdminEmails); err != nil {
+		log.Fatalf("Failed to promote admins: %v", err)
+	}
+
 	// Initialize notes components
 	noteRepo := notes.NewPostgresNoteRepository(db)
 	tagRepo := notes.NewPostgresTagRepository(db)
@@ -148,15 +379,25 @@ func run() error {
 		versionRepo,
 		qdrantClient,
 		embeddingService,
+		&notesPreferenceProvider{authService: authService},
 		cfg.Pagination.DefaultPageSize,
 		cfg.Pagination.MaxPageSize,
+		cfg.Trash.Retention,
+		notes.HybridSearchConfig{
+			LexicalWeight:  cfg.Search.LexicalWeight,
+			SemanticWeight: cfg.Search.SemanticWeight,
+			RRFK:           cfg.Search.RRFK,
+		},
 	)
 
+	// Permanently delete notes that outlived the trash retention period
+	go notesService.RunTrashPurge(context.Background(), cfg.Trash.PurgeInterval)
+
 	notesHandler := notes.NewHandler(notesService)
 
 	// Initialize chat components
 	chatRepo := chat.NewPostgresChatRepository(db)
-	openaiClient := chat.NewOpenAIClient(cfg.OpenAI.APIKey)
+	openaiClient := chat.NewOpenAIClient(cfg.OpenAI.APIKey, cfg.Chat.Model)
 
 	// Create note repository adapter for chat (simple wrapper)
 	noteRepoForChat := &chatNoteRepository{noteRepo: noteRepo}
@@ -165,11 +406,12 @@ func run() error {
 		chatRepo,
 		noteRepoForChat,
 		openaiClient,
+		&chatPreferenceProvider{authService: authService, models: cfg.Chat.Models},
 	)
 	chatHandler := chat.NewHandler(chatService)
 
 	// Initialize server
-	srv := server.New(cfg.CORS.AllowedOrigins)
+	srv := server.New(cfg.CORS.AllowedOrigins, cfg.Server.TrustedProxies)
 	srv.RegisterRoutes(
 		authHandler,
 		authService,
@@ -2,6 +2,11 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"os"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
@@ -16,10 +21,18 @@ type Config struct {
 	JWT        JWTConfig
 	CORS       CORSConfig
 	Pagination PaginationConfig
+	App        AppConfig
+	Auth       AuthConfig
+	Mail       MailConfig
+	OIDC       OIDCConfig
+	Chat       ChatConfig
+	Trash      TrashConfig
+	Search     SearchConfig
 }
 
 type ServerConfig struct {
-	Port string
+	Port           string
+	TrustedProxies []string // CIDR ranges of reverse proxies whose X-Forwarded-For is trusted
 }
 
 type DatabaseConfig struct {
@@ -51,12 +64,26 @@ type OpenAIConfig struct {
 }
 
 type JWTConfig struct {
-	Secret             string
-	RefreshSecret      string
+	Secret             string // HS256 key "default"; also verifies tokens issued without a key ID
+	RefreshSecret      string // HS256 refresh key "default"
+	ActiveKey          string // ID of the key that signs new access tokens
+	Keys               []JWTKeyConfig
+	RefreshActiveKey   string // ID of the key that signs new refresh tokens
+	RefreshKeys        []JWTKeyConfig
 	AccessTokenExpiry  time.Duration
 	RefreshTokenExpiry time.Duration
 }
 
+// JWTKeyConfig configures one signing key, read from a [jwt.keys.<id>] or
+// [jwt.refresh_keys.<id>] table. Keys that are not active only verify tokens.
+type JWTKeyConfig struct {
+	ID             string
+	Algorithm      string // "HS256", "RS256" or "EdDSA"
+	Secret         string // HS256 only
+	PrivateKeyFile string // PEM file; required for the active RS256/EdDSA key
+	PublicKeyFile  string // PEM file; enough for retired RS256/EdDSA keys
+}
+
 type CORSConfig struct {
 	AllowedOrigins []string
 }
@@ -66,6 +93,69 @@ type PaginationConfig struct {
 	MaxPageSize     int
 }
 
+type AppConfig struct {
+	BaseURL string // Web app URL used for links in emails
+}
+
+type AuthConfig struct {
+	PasswordResetExpiry      time.Duration
+	EmailVerificationExpiry  time.Duration
+	TwoFactorChallengeExpiry time.Duration // Time allowed between password and 2FA code during login
+	RequireVerifiedEmail     bool          // Block note-writing routes until the email is verified
+	LoginLockoutThreshold    int           // Failed logins per account before it is locked
+	IPLockoutThreshold       int           // Failed logins per IP before it is locked
+	LockoutBaseDuration      time.Duration
+	LockoutMaxDuration       time.Duration // Cap for the exponentially growing lockout
+	RegistrationLimit        int           // Registrations allowed per IP within RegistrationWindow
+	RegistrationWindow       time.Duration
+	RegistrationMode         string   // "open", "invite" or "disabled"
+	AdminEmails              []string // Existing users with these emails are made admins at startup
+}
+
+type MailConfig struct {
+	Provider     string // "smtp" or "log"
+	From         string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	OutputDir    string // Directory for .eml files when provider is "log" (empty logs to stdout)
+}
+
+type ChatConfig struct {
+	Model  string   // Model used when the user has no chat_model preference
+	Models []string // Models users may choose in their preferences
+}
+
+type TrashConfig struct {
+	Retention     time.Duration // Trashed notes are purged after this long
+	PurgeInterval time.Duration // How often the purge runs
+}
+
+// SearchConfig weights the full-text and vector rankings of hybrid search
+type SearchConfig struct {
+	LexicalWeight  float64
+	SemanticWeight float64
+	RRFK           int // Reciprocal rank fusion constant
+}
+
+type OIDCConfig struct {
+	Providers []OIDCProviderConfig
+}
+
+// OIDCProviderConfig configures one OpenID Connect identity provider,
+// read from an [oidc.providers.<name>] table
+type OIDCProviderConfig struct {
+	Name         string
+	DisplayName  string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string // Web app page that receives the code and state
+	Scopes       []string
+	AllowSignup  bool // Create accounts for verified emails that have none yet
+}
+
 // Load reads configuration from TOML file
 func Load(env string) (*Config, error) {
 	v := viper.New()
@@ -76,6 +166,32 @@ func Load(env string) (*Config, error) {
 	v.AddConfigPath(".conf")
 	v.AddConfigPath(".")
 
+	// Defaults for optional settings
+	v.SetDefault("app.base_url", "http://localhost:5173")
+	v.SetDefault("jwt.active_key", jwtLegacyKeyID)
+	v.SetDefault("jwt.refresh_active_key", jwtLegacyKeyID)
+	v.SetDefault("auth.password_reset_expiry", "1h")
+	v.SetDefault("auth.email_verification_expiry", "24h")
+	v.SetDefault("auth.two_factor_challenge_expiry", "5m")
+	v.SetDefault("auth.require_verified_email", false)
+	v.SetDefault("auth.lockout_threshold", 5)
+	v.SetDefault("auth.ip_lockout_threshold", 20)
+	v.SetDefault("auth.lockout_base_duration", "1m")
+	v.SetDefault("auth.lockout_max_duration", "1h")
+	v.SetDefault("auth.registration_limit", 5)
+	v.SetDefault("auth.registration_window", "1h")
+	v.SetDefault("auth.registration_mode", "open")
+	v.SetDefault("mail.provider", "log")
+	v.SetDefault("mail.from", "Yapgan <no-reply@localhost>")
+	v.SetDefault("mail.smtp_port", 587)
+	v.SetDefault("trash.retention", "720h")
+	v.SetDefault("trash.purge_interval", "1h")
+	v.SetDefault("search.lexical_weight", 1.0)
+	v.SetDefault("search.semantic_weight", 1.0)
+	v.SetDefault("search.rrf_k", 60)
+	v.SetDefault("chat.model", "gpt-5-nano")
+	v.SetDefault("chat.models", []string{"gpt-5-nano", "gpt-5-mini", "gpt-5"})
+
 	// Read config file
 	if err := v.ReadInConfig(); err != nil {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
@@ -86,6 +202,7 @@ func Load(env string) (*Config, error) {
 
 	// Server config
 	cfg.Server.Port = v.GetString("server.port")
+	cfg.Server.TrustedProxies = v.GetStringSlice("server.trusted_proxies")
 
 	// Database config
 	cfg.Database.Host = v.GetString("database.host")
@@ -121,6 +238,10 @@ func Load(env string) (*Config, error) {
 	// JWT config
 	cfg.JWT.Secret = v.GetString("jwt.secret")
 	cfg.JWT.RefreshSecret = v.GetString("jwt.refresh_secret")
+	cfg.JWT.ActiveKey = v.GetString("jwt.active_key")
+	cfg.JWT.RefreshActiveKey = v.GetString("jwt.refresh_active_key")
+	cfg.JWT.Keys = loadJWTKeys(v, "jwt.keys", "JWT_KEY_", cfg.JWT.Secret)
+	cfg.JWT.RefreshKeys = loadJWTKeys(v, "jwt.refresh_keys", "JWT_REFRESH_KEY_", cfg.JWT.RefreshSecret)
 
 	// Parse durations
 	accessExpiry, err := time.ParseDuration(v.GetString("jwt.access_token_expiry"))
@@ -142,6 +263,122 @@ func Load(env string) (*Config, error) {
 	cfg.Pagination.DefaultPageSize = v.GetInt("pagination.default_page_size")
 	cfg.Pagination.MaxPageSize = v.GetInt("pagination.max_page_size")
 
+	// App config
+	cfg.App.BaseURL = v.GetString("app.base_url")
+
+	// Auth config
+	resetExpiry, err := time.ParseDuration(v.GetString("auth.password_reset_expiry"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid password reset expiry: %w", err)
+	}
+	cfg.Auth.PasswordResetExpiry = resetExpiry
+
+	verificationExpiry, err := time.ParseDuration(v.GetString("auth.email_verification_expiry"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid email verification expiry: %w", err)
+	}
+	cfg.Auth.EmailVerificationExpiry = verificationExpiry
+
+	challengeExpiry, err := time.ParseDuration(v.GetString("auth.two_factor_challenge_expiry"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid two-factor challenge expiry: %w", err)
+	}
+	cfg.Auth.TwoFactorChallengeExpiry = challengeExpiry
+	cfg.Auth.RequireVerifiedEmail = v.GetBool("auth.require_verified_email")
+	cfg.Auth.LoginLockoutThreshold = v.GetInt("auth.lockout_threshold")
+	cfg.Auth.IPLockoutThreshold = v.GetInt("auth.ip_lockout_threshold")
+	cfg.Auth.RegistrationLimit = v.GetInt("auth.registration_limit")
+
+	lockoutBase, err := time.ParseDuration(v.GetString("auth.lockout_base_duration"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid lockout base duration: %w", err)
+	}
+	cfg.Auth.LockoutBaseDuration = lockoutBase
+
+	lockoutMax, err := time.ParseDuration(v.GetString("auth.lockout_max_duration"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid lockout max duration: %w", err)
+	}
+	cfg.Auth.LockoutMaxDuration = lockoutMax
+
+	registrationWindow, err := time.ParseDuration(v.GetString("auth.registration_window"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid registration window: %w", err)
+	}
+	cfg.Auth.RegistrationWindow = registrationWindow
+	cfg.Auth.RegistrationMode = v.GetString("auth.registration_mode")
+	cfg.Auth.AdminEmails = v.GetStringSlice("auth.admin_emails")
+
+	// Mail config
+	cfg.Mail.Provider = v.GetString("mail.provider")
+	cfg.Mail.From = v.GetString("mail.from")
+	cfg.Mail.SMTPHost = v.GetString("mail.smtp_host")
+	cfg.Mail.SMTPPort = v.GetInt("mail.smtp_port")
+	cfg.Mail.SMTPUsername = v.GetString("mail.smtp_username")
+	cfg.Mail.SMTPPassword = v.GetString("mail.smtp_password")
+	cfg.Mail.OutputDir = v.GetString("mail.output_dir")
+
+	// Allow SMTP password from environment variable
+	if cfg.Mail.SMTPPassword == "" {
+		v.SetEnvPrefix("SMTP")
+		v.BindEnv("password")
+		cfg.Mail.SMTPPassword = v.GetString("password")
+	}
+
+	// Trash config
+	trashRetention, err := time.ParseDuration(v.GetString("trash.retention"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid trash retention: %w", err)
+	}
+	cfg.Trash.Retention = trashRetention
+
+	purgeInterval, err := time.ParseDuration(v.GetString("trash.purge_interval"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid trash purge interval: %w", err)
+	}
+	cfg.Trash.PurgeInterval = purgeInterval
+
+	// Search config
+	cfg.Search.LexicalWeight = v.GetFloat64("search.lexical_weight")
+	cfg.Search.SemanticWeight = v.GetFloat64("search.semantic_weight")
+	cfg.Search.RRFK = v.GetInt("search.rrf_k")
+
+	// Chat config
+	cfg.Chat.Model = v.GetString("chat.model")
+	cfg.Chat.Models = v.GetStringSlice("chat.models")
+
+	// OIDC config
+	providerNames := make([]string, 0)
+	for name := range v.GetStringMap("oidc.providers") {
+		providerNames = append(providerNames, name)
+	}
+	sort.Strings(providerNames)
+
+	for _, name := range providerNames {
+		prefix := "oidc.providers." + name + "."
+		provider := OIDCProviderConfig{
+			Name:         name,
+			DisplayName:  v.GetString(prefix + "display_name"),
+			Issuer:       v.GetString(prefix + "issuer"),
+			ClientID:     v.GetString(prefix + "client_id"),
+			ClientSecret: v.GetString(prefix + "client_secret"),
+			RedirectURL:  v.GetString(prefix + "redirect_url"),
+			Scopes:       v.GetStringSlice(prefix + "scopes"),
+			AllowSignup:  v.GetBool(prefix + "allow_signup"),
+		}
+
+		if provider.DisplayName == "" {
+			provider.DisplayName = name
+		}
+
+		// Allow client secret from environment variable, e.g. OIDC_COMPANY_CLIENT_SECRET
+		if provider.ClientSecret == "" {
+			provider.ClientSecret = os.Getenv("OIDC_" + strings.ToUpper(name) + "_CLIENT_SECRET")
+		}
+
+		cfg.OIDC.Providers = append(cfg.OIDC.Providers, provider)
+	}
+
 	// Validate required fields
 	if err := cfg.Validate(); err != nil {
 		return nil, fmt.Errorf("config validation failed: %w", err)
@@ -156,6 +393,12 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("server.port is required")
 	}
 
+	for _, cidr := range c.Server.TrustedProxies {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("server.trusted_proxies: invalid CIDR %q", cidr)
+		}
+	}
+
 	if c.Database.Host == "" {
 		return fmt.Errorf("database.host is required")
 	}
@@ -164,22 +407,30 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("database.name is required")
 	}
 
-	if c.JWT.Secret == "" {
-		return fmt.Errorf("jwt.secret is required")
+	if c.JWT.Secret == "" && len(c.JWT.Keys) == 0 {
+		return fmt.Errorf("jwt.secret or jwt.keys is required")
 	}
 
-	if len(c.JWT.Secret) < 32 {
+	if c.JWT.Secret != "" && len(c.JWT.Secret) < 32 {
 		return fmt.Errorf("jwt.secret must be at least 32 characters")
 	}
 
-	if c.JWT.RefreshSecret == "" {
-		return fmt.Errorf("jwt.refresh_secret is required")
+	if c.JWT.RefreshSecret == "" && len(c.JWT.RefreshKeys) == 0 {
+		return fmt.Errorf("jwt.refresh_secret or jwt.refresh_keys is required")
 	}
 
-	if len(c.JWT.RefreshSecret) < 32 {
+	if c.JWT.RefreshSecret != "" && len(c.JWT.RefreshSecret) < 32 {
 		return fmt.Errorf("jwt.refresh_secret must be at least 32 characters")
 	}
 
+	if err := validateJWTKeys("jwt.keys", c.JWT.ActiveKey, c.JWT.Keys); err != nil {
+		return err
+	}
+
+	if err := validateJWTKeys("jwt.refresh_keys", c.JWT.RefreshActiveKey, c.JWT.RefreshKeys); err != nil {
+		return err
+	}
+
 	if c.JWT.AccessTokenExpiry == 0 {
 		return fmt.Errorf("jwt.access_token_expiry is required")
 	}
@@ -196,6 +447,143 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("pagination.max_page_size must be greater than 0")
 	}
 
+	if c.Auth.PasswordResetExpiry <= 0 {
+		return fmt.Errorf("auth.password_reset_expiry must be greater than 0")
+	}
+
+	if c.Auth.EmailVerificationExpiry <= 0 {
+		return fmt.Errorf("auth.email_verification_expiry must be greater than 0")
+	}
+
+	if c.Auth.TwoFactorChallengeExpiry <= 0 {
+		return fmt.Errorf("auth.two_factor_challenge_expiry must be greater than 0")
+	}
+
+	if c.Auth.LoginLockoutThreshold <= 0 || c.Auth.IPLockoutThreshold <= 0 {
+		return fmt.Errorf("auth.lockout_threshold and auth.ip_lockout_threshold must be greater than 0")
+	}
+
+	if c.Auth.LockoutBaseDuration <= 0 || c.Auth.LockoutMaxDuration < c.Auth.LockoutBaseDuration {
+		return fmt.Errorf("auth.lockout_base_duration must be greater than 0 and not exceed auth.lockout_max_duration")
+	}
+
+	if c.Auth.RegistrationLimit <= 0 || c.Auth.RegistrationWindow <= 0 {
+		return fmt.Errorf("auth.registration_limit and auth.registration_window must be greater than 0")
+	}
+
+	if c.Mail.Provider != "smtp" && c.Mail.Provider != "log" {
+		return fmt.Errorf("mail.provider must be \"smtp\" or \"log\"")
+	}
+
+	switch c.Auth.RegistrationMode {
+	case "open", "invite", "disabled":
+	default:
+		return fmt.Errorf("auth.registration_mode must be \"open\", \"invite\" or \"disabled\"")
+	}
+
+	if c.Trash.Retention <= 0 || c.Trash.PurgeInterval <= 0 {
+		return fmt.Errorf("trash.retention and trash.purge_interval must be greater than 0")
+	}
+
+	if c.Search.LexicalWeight < 0 || c.Search.SemanticWeight < 0 || c.Search.LexicalWeight+c.Search.SemanticWeight == 0 {
+		return fmt.Errorf("search.lexical_weight and search.semantic_weight must not be negative or both 0")
+	}
+
+	if c.Search.RRFK <= 0 {
+		return fmt.Errorf("search.rrf_k must be greater than 0")
+	}
+
+	if !slices.Contains(c.Chat.Models, c.Chat.Model) {
+		return fmt.Errorf("chat.model must be one of chat.models")
+	}
+
+	for _, provider := range c.OIDC.Providers {
+		if provider.Issuer == "" || provider.ClientID == "" || provider.RedirectURL == "" {
+			return fmt.Errorf("oidc.providers.%s requires issuer, client_id and redirect_url", provider.Name)
+		}
+	}
+
+	if c.Mail.Provider == "smtp" && c.Mail.SMTPHost == "" {
+		return fmt.Errorf("mail.smtp_host is required when mail.provider is \"smtp\"")
+	}
+
+	return nil
+}
+
+// jwtLegacyKeyID is the ID given to the key built from jwt.secret / jwt.refresh_secret
+const jwtLegacyKeyID = "default"
+
+// loadJWTKeys reads the key tables under prefix. The legacy secret, when set, is
+// added as the HS256 key "default".
+func loadJWTKeys(v *viper.Viper, prefix, envPrefix, legacySecret string) []JWTKeyConfig {
+	keys := make([]JWTKeyConfig, 0)
+	if legacySecret != "" {
+		keys = append(keys, JWTKeyConfig{
+			ID:        jwtLegacyKeyID,
+			Algorithm: "HS256",
+			Secret:    legacySecret,
+		})
+	}
+
+	keyIDs := make([]string, 0)
+	for id := range v.GetStringMap(prefix) {
+		keyIDs = append(keyIDs, id)
+	}
+	sort.Strings(keyIDs)
+
+	for _, id := range keyIDs {
+		keyPrefix := prefix + "." + id + "."
+		key := JWTKeyConfig{
+			ID:             id,
+			Algorithm:      v.GetString(keyPrefix + "algorithm"),
+			Secret:         v.GetString(keyPrefix + "secret"),
+			PrivateKeyFile: v.GetString(keyPrefix + "private_key_file"),
+			PublicKeyFile:  v.GetString(keyPrefix + "public_key_file"),
+		}
+
+		if key.Algorithm == "" {
+			key.Algorithm = "HS256"
+		}
+
+		// Allow HS256 secrets from environment variables, e.g. JWT_KEY_2025_06_SECRET
+		if key.Secret == "" {
+			envID := strings.ToUpper(strings.ReplaceAll(id, "-", "_"))
+			key.Secret = os.Getenv(envPrefix + envID + "_SECRET")
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+// validateJWTKeys checks that key IDs are unique and the active key exists
+func validateJWTKeys(prefix, activeKey string, keys []JWTKeyConfig) error {
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if seen[key.ID] {
+			return fmt.Errorf("%s.%s conflicts with the key built from the legacy secret", prefix, key.ID)
+		}
+		seen[key.ID] = true
+
+		switch key.Algorithm {
+		case "HS256":
+			if len(key.Secret) < 32 {
+				return fmt.Errorf("%s.%s.secret must be at least 32 characters", prefix, key.ID)
+			}
+		case "RS256", "EdDSA":
+			if key.PrivateKeyFile == "" && key.PublicKeyFile == "" {
+				return fmt.Errorf("%s.%s requires private_key_file or public_key_file", prefix, key.ID)
+			}
+		default:
+			return fmt.Errorf("%s.%s.algorithm must be \"HS256\", \"RS256\" or \"EdDSA\"", prefix, key.ID)
+		}
+	}
+
+	if !seen[activeKey] {
+		return fmt.Errorf("active key %q is not defined in %s", activeKey, prefix)
+	}
+
 	return nil
 }
 
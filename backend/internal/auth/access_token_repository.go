@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresAccessTokenRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresAccessTokenRepository(db *pgxpool.Pool) *PostgresAccessTokenRepository {
+	return &PostgresAccessTokenRepository{db: db}
+}
+
+func (r *PostgresAccessTokenRepository) Create(ctx context.Context, token *PersonalAccessToken) error {
+	query := `
+		INSERT INTO personal_access_tokens (id, user_id, name, token_hash, token_prefix, scopes, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		token.ID, token.UserID, token.Name, token.TokenHash, token.TokenPrefix,
+		token.Scopes, token.ExpiresAt, token.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create access token: %w", err)
+	}
+
+	return nil
+}
+
+func (r *PostgresAccessTokenRepository) FindByHash(ctx context.Context, tokenHash string) (*PersonalAccessToken, error) {
+	token := &PersonalAccessToken{}
+
+	query := `
+		SELECT id, user_id, name, token_hash, token_prefix, scopes, last_used_at, expires_at, revoked_at, created_at
+		FROM personal_access_tokens
+		WHERE token_hash = $1
+	`
+
+	err := r.db.QueryRow(ctx, query, tokenHash).Scan(
+		&token.ID, &token.UserID, &token.Name, &token.TokenHash, &token.TokenPrefix,
+		&token.Scopes, &token.LastUsedAt, &token.ExpiresAt, &token.RevokedAt, &token.CreatedAt,
+	)
+
+	if err == pgx.ErrNoRows {
+		return nil, fmt.Errorf("access token not found")
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to find access token: %w", err)
+	}
+
+	return token, nil
+}
+
+// ListByUser returns the user's tokens that have not been revoked
+func (r *PostgresAccessTokenRepository) ListByUser(ctx context.Context, userID string) ([]PersonalAccessToken, error) {
+	query := `
+		SELECT id, user_id, name, token_hash, token_prefix, scopes, last_used_at, expires_at, revoked_at, created_at
+		FROM personal_access_tokens
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list access tokens: %w", err)
+	}
+	defer rows.Close()
+
+	tokens := []PersonalAccessToken{}
+	for rows.Next() {
+		var token PersonalAccessToken
+		err := rows.Scan(
+			&token.ID, &token.UserID, &token.Name, &token.TokenHash, &token.TokenPrefix,
+			&token.Scopes, &token.LastUsedAt, &token.ExpiresAt, &token.RevokedAt, &token.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan access token: %w", err)
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens, nil
+}
+
+// TouchLastUsed records token usage, writing at most once per minute
+func (r *PostgresAccessTokenRepository) TouchLastUsed(ctx context.Context, tokenID string) error {
+	query := `
+		UPDATE personal_access_tokens
+		SET last_used_at = NOW()
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')
+	`
+
+	_, err := r.db.Exec(ctx, query, tokenID)
+	if err != nil {
+		return fmt.Errorf("failed to update access token: %w", err)
+	}
+
+	return nil
+}
+
+func (r *PostgresAccessTokenRepository) Revoke(ctx context.Context, userID, tokenID string) error {
+	query := `
+		UPDATE personal_access_tokens
+		SET revoked_at = $1
+		WHERE id = $2 AND user_id = $3 AND revoked_at IS NULL
+	`
+
+	result, err := r.db.Exec(ctx, query, time.Now(), tokenID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke access token: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("access token not found")
+	}
+
+	return nil
+}
@@ -1,7 +1,10 @@
 package auth
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/labstack/echo/v4"
 )
@@ -28,8 +31,14 @@ func (h *Handler) Register(c echo.Context) error {
 		})
 	}
 
+	req.Client = clientInfo(c)
+
 	resp, err := h.service.Register(c.Request().Context(), req)
 	if err != nil {
+		var locked *LockedError
+		if errors.As(err, &locked) {
+			return lockedResponse(c, locked)
+		}
 		return c.JSON(http.StatusBadRequest, map[string]string{
 			"error": err.Error(),
 		})
@@ -52,8 +61,14 @@ func (h *Handler) Login(c echo.Context) error {
 		})
 	}
 
+	req.Client = clientInfo(c)
+
 	resp, err := h.service.Login(c.Request().Context(), req)
 	if err != nil {
+		var locked *LockedError
+		if errors.As(err, &locked) {
+			return lockedResponse(c, locked)
+		}
 		return c.JSON(http.StatusUnauthorized, map[string]string{
 			"error": err.Error(),
 		})
@@ -61,3 +76,658 @@ func (h *Handler) Login(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, resp)
 }
+
+func (h *Handler) Refresh(c echo.Context) error {
+	var req RefreshRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid request body",
+		})
+	}
+
+	if req.RefreshToken == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "refresh_token is required",
+		})
+	}
+
+	resp, err := h.service.Refresh(c.Request().Context(), req)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// Logout invalidates the session of the access token used for this request
+func (h *Handler) Logout(c echo.Context) error {
+	userID := c.Get("user_id").(string)
+	sessionID, _ := c.Get("session_id").(string)
+
+	if err := h.service.Logout(c.Request().Context(), userID, sessionID); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ListSessions returns the user's active sessions
+func (h *Handler) ListSessions(c echo.Context) error {
+	userID := c.Get("user_id").(string)
+	sessionID, _ := c.Get("session_id").(string)
+
+	resp, err := h.service.ListSessions(c.Request().Context(), userID, sessionID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// RevokeSession revokes a single session
+func (h *Handler) RevokeSession(c echo.Context) error {
+	userID := c.Get("user_id").(string)
+	sessionID := c.Param("id")
+
+	if err := h.service.RevokeSession(c.Request().Context(), userID, sessionID); err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// RevokeAllSessions revokes every session of the user
+func (h *Handler) RevokeAllSessions(c echo.Context) error {
+	userID := c.Get("user_id").(string)
+
+	count, err := h.service.RevokeAllSessions(c.Request().Context(), userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message":          "All sessions revoked successfully",
+		"sessions_revoked": count,
+	})
+}
+
+// clientInfo extracts the device details recorded on a new session
+func clientInfo(c echo.Context) ClientInfo {
+	return ClientInfo{
+		UserAgent: c.Request().UserAgent(),
+		IPAddress: c.RealIP(),
+	}
+}
+
+// CreateAccessToken creates a personal access token
+func (h *Handler) CreateAccessToken(c echo.Context) error {
+	userID := c.Get("user_id").(string)
+
+	var req CreateAccessTokenRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid request body",
+		})
+	}
+
+	resp, err := h.service.CreateAccessToken(c.Request().Context(), userID, req)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusCreated, resp)
+}
+
+// ListAccessTokens lists the user's personal access tokens
+func (h *Handler) ListAccessTokens(c echo.Context) error {
+	userID := c.Get("user_id").(string)
+
+	resp, err := h.service.ListAccessTokens(c.Request().Context(), userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// RevokeAccessToken revokes a personal access token
+func (h *Handler) RevokeAccessToken(c echo.Context) error {
+	userID := c.Get("user_id").(string)
+	tokenID := c.Param("id")
+
+	if err := h.service.RevokeAccessToken(c.Request().Context(), userID, tokenID); err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ForgotPassword emails a password reset link
+func (h *Handler) ForgotPassword(c echo.Context) error {
+	var req ForgotPasswordRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid request body",
+		})
+	}
+
+	if req.Email == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "email is required",
+		})
+	}
+
+	if err := h.service.ForgotPassword(c.Request().Context(), req); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusAccepted, map[string]string{
+		"message": "If an account exists for this email, a reset link has been sent",
+	})
+}
+
+// ResetPassword sets a new password using a reset token
+func (h *Handler) ResetPassword(c echo.Context) error {
+	var req ResetPasswordRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid request body",
+		})
+	}
+
+	if req.Token == "" || req.NewPassword == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "token and new_password are required",
+		})
+	}
+
+	if err := h.service.ResetPassword(c.Request().Context(), req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Password has been reset, please log in again",
+	})
+}
+
+// ChangePassword changes the password of the logged-in user
+func (h *Handler) ChangePassword(c echo.Context) error {
+	userID := c.Get("user_id").(string)
+
+	var req ChangePasswordRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid request body",
+		})
+	}
+
+	if req.NewPassword == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "new_password is required",
+		})
+	}
+
+	req.Client = clientInfo(c)
+
+	resp, err := h.service.ChangePassword(c.Request().Context(), userID, req)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// VerifyEmail confirms an email address with a verification token
+func (h *Handler) VerifyEmail(c echo.Context) error {
+	var req VerifyEmailRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid request body",
+		})
+	}
+
+	if req.Token == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "token is required",
+		})
+	}
+
+	if err := h.service.VerifyEmail(c.Request().Context(), req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Email verified successfully",
+	})
+}
+
+// ResendVerificationEmail sends a new verification link to the logged-in user
+func (h *Handler) ResendVerificationEmail(c echo.Context) error {
+	userID := c.Get("user_id").(string)
+
+	if err := h.service.ResendVerificationEmail(c.Request().Context(), userID); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusAccepted, map[string]string{
+		"message": "Verification email sent",
+	})
+}
+
+// EnrollTOTP starts two-factor enrollment and returns the secret for the authenticator app
+func (h *Handler) EnrollTOTP(c echo.Context) error {
+	userID := c.Get("user_id").(string)
+
+	resp, err := h.service.EnrollTOTP(c.Request().Context(), userID)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// ConfirmTOTP enables two-factor authentication and returns the recovery codes
+func (h *Handler) ConfirmTOTP(c echo.Context) error {
+	userID := c.Get("user_id").(string)
+
+	var req ConfirmTOTPRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid request body",
+		})
+	}
+
+	if req.Code == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "code is required",
+		})
+	}
+
+	resp, err := h.service.ConfirmTOTP(c.Request().Context(), userID, req)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// VerifyTwoFactor completes a two-step login with a TOTP or recovery code
+func (h *Handler) VerifyTwoFactor(c echo.Context) error {
+	var req VerifyTwoFactorRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid request body",
+		})
+	}
+
+	if req.ChallengeToken == "" || (req.Code == "" && req.RecoveryCode == "") {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "challenge_token and code or recovery_code are required",
+		})
+	}
+
+	req.Client = clientInfo(c)
+
+	resp, err := h.service.VerifyTwoFactor(c.Request().Context(), req)
+	if err != nil {
+		var locked *LockedError
+		if errors.As(err, &locked) {
+			return lockedResponse(c, locked)
+		}
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// DisableTOTP turns two-factor authentication off after re-authentication
+func (h *Handler) DisableTOTP(c echo.Context) error {
+	userID := c.Get("user_id").(string)
+	sessionID, _ := c.Get("session_id").(string)
+
+	var req DisableTOTPRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid request body",
+		})
+	}
+
+	if req.Code == "" && req.RecoveryCode == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "code or recovery_code is required",
+		})
+	}
+
+	if err := h.service.DisableTOTP(c.Request().Context(), userID, sessionID, req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "Two-factor authentication disabled",
+	})
+}
+
+// ListSecurityEvents returns recent lockouts on the user's account
+func (h *Handler) ListSecurityEvents(c echo.Context) error {
+	userID := c.Get("user_id").(string)
+
+	resp, err := h.service.ListSecurityEvents(c.Request().Context(), userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// lockedResponse answers a locked-out request with 429 and Retry-After in seconds
+func lockedResponse(c echo.Context, locked *LockedError) error {
+	seconds := int(math.Ceil(locked.RetryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	c.Response().Header().Set("Retry-After", strconv.Itoa(seconds))
+	return c.JSON(http.StatusTooManyRequests, map[string]string{
+		"error": locked.Error(),
+	})
+}
+
+// GetProfile returns the logged-in user's profile and preferences
+func (h *Handler) GetProfile(c echo.Context) error {
+	userID := c.Get("user_id").(string)
+
+	profile, err := h.service.GetProfile(c.Request().Context(), userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, profile)
+}
+
+// UpdateProfile changes the logged-in user's profile and, optionally, preferences
+func (h *Handler) UpdateProfile(c echo.Context) error {
+	userID := c.Get("user_id").(string)
+
+	var req UpdateProfileRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid request body",
+		})
+	}
+
+	profile, err := h.service.UpdateProfile(c.Request().Context(), userID, req)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, profile)
+}
+
+// GetPreferences returns the logged-in user's preferences document
+func (h *Handler) GetPreferences(c echo.Context) error {
+	userID := c.Get("user_id").(string)
+
+	prefs, err := h.service.GetPreferences(c.Request().Context(), userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, prefs)
+}
+
+// UpdatePreferences changes the logged-in user's preferences
+func (h *Handler) UpdatePreferences(c echo.Context) error {
+	userID := c.Get("user_id").(string)
+
+	var req UpdatePreferencesRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid request body",
+		})
+	}
+
+	prefs, err := h.service.UpdatePreferences(c.Request().Context(), userID, req)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, prefs)
+}
+
+// DeleteAccount permanently deletes the logged-in user and all of their data
+func (h *Handler) DeleteAccount(c echo.Context) error {
+	userID := c.Get("user_id").(string)
+
+	sessionID, _ := c.Get("session_id").(string)
+
+	var req DeleteAccountRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid request body",
+		})
+	}
+
+	report, err := h.service.DeleteAccount(c.Request().Context(), userID, sessionID, req)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, report)
+}
+
+// JWKS publishes the public keys that verify access tokens
+func (h *Handler) JWKS(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.service.JWKS())
+}
+
+// ListOIDCProviders returns the configured single sign-on providers
+func (h *Handler) ListOIDCProviders(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.service.ListOIDCProviders())
+}
+
+// StartOIDCLogin returns the authorization URL the web app should redirect to
+func (h *Handler) StartOIDCLogin(c echo.Context) error {
+	resp, err := h.service.StartOIDCLogin(c.Request().Context(), c.Param("provider"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// OIDCCallback completes a single sign-on login with the code and state from the provider
+func (h *Handler) OIDCCallback(c echo.Context) error {
+	var req OIDCCallbackRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid request body",
+		})
+	}
+
+	if req.Code == "" || req.State == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "code and state are required",
+		})
+	}
+
+	req.Client = clientInfo(c)
+
+	resp, err := h.service.CompleteOIDCLogin(c.Request().Context(), c.Param("provider"), req)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// ListUsers returns users with usage counts (admin only)
+func (h *Handler) ListUsers(c echo.Context) error {
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	perPage, _ := strconv.Atoi(c.QueryParam("per_page"))
+
+	req := ListUsersRequest{
+		Page:    page,
+		PerPage: perPage,
+		Search:  c.QueryParam("search"),
+	}
+
+	resp, err := h.service.ListUsers(c.Request().Context(), req)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// DisableUser disables an account and ends its sessions (admin only)
+func (h *Handler) DisableUser(c echo.Context) error {
+	return h.setUserDisabled(c, true)
+}
+
+// EnableUser re-enables a disabled account (admin only)
+func (h *Handler) EnableUser(c echo.Context) error {
+	return h.setUserDisabled(c, false)
+}
+
+func (h *Handler) setUserDisabled(c echo.Context, disabled bool) error {
+	adminID := c.Get("user_id").(string)
+
+	err := h.service.SetUserDisabled(c.Request().Context(), adminID, c.Param("id"), disabled)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// SetUserRole changes a user's role (admin only)
+func (h *Handler) SetUserRole(c echo.Context) error {
+	adminID := c.Get("user_id").(string)
+
+	var req SetUserRoleRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid request body",
+		})
+	}
+
+	if err := h.service.SetUserRole(c.Request().Context(), adminID, c.Param("id"), req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ForceLogout ends every session of a user (admin only)
+func (h *Handler) ForceLogout(c echo.Context) error {
+	revoked, err := h.service.ForceLogout(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]int{
+		"revoked": revoked,
+	})
+}
+
+// CreateInvite generates an invite code (admin only)
+func (h *Handler) CreateInvite(c echo.Context) error {
+	adminID := c.Get("user_id").(string)
+
+	var req CreateInviteRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid request body",
+		})
+	}
+
+	resp, err := h.service.CreateInvite(c.Request().Context(), adminID, req)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusCreated, resp)
+}
+
+// ListInvites returns all invite codes (admin only)
+func (h *Handler) ListInvites(c echo.Context) error {
+	resp, err := h.service.ListInvites(c.Request().Context())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// DeleteInvite revokes an unused invite code (admin only)
+func (h *Handler) DeleteInvite(c echo.Context) error {
+	if err := h.service.DeleteInvite(c.Request().Context(), c.Param("id")); err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresInviteRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresInviteRepository(db *pgxpool.Pool) *PostgresInviteRepository {
+	return &PostgresInviteRepository{db: db}
+}
+
+func (r *PostgresInviteRepository) Create(ctx context.Context, invite *InviteCode) error {
+	query := `
+		INSERT INTO invite_codes (id, code_hash, email, created_by, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		invite.ID, invite.CodeHash, invite.Email, invite.CreatedBy, invite.ExpiresAt, invite.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create invite: %w", err)
+	}
+
+	return nil
+}
+
+func (r *PostgresInviteRepository) List(ctx context.Context) ([]InviteCode, error) {
+	query := `
+		SELECT id, email, created_by, used_by, used_at, expires_at, created_at
+		FROM invite_codes
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list invites: %w", err)
+	}
+	defer rows.Close()
+
+	invites := []InviteCode{}
+	for rows.Next() {
+		var invite InviteCode
+		err := rows.Scan(
+			&invite.ID,
+			&invite.Email,
+			&invite.CreatedBy,
+			&invite.UsedBy,
+			&invite.UsedAt,
+			&invite.ExpiresAt,
+			&invite.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan invite: %w", err)
+		}
+		invites = append(invites, invite)
+	}
+
+	return invites, nil
+}
+
+// Delete removes an invite that has not been used yet
+func (r *PostgresInviteRepository) Delete(ctx context.Context, inviteID string) error {
+	result, err := r.db.Exec(ctx, `DELETE FROM invite_codes WHERE id = $1 AND used_at IS NULL`, inviteID)
+	if err != nil {
+		return fmt.Errorf("failed to delete invite: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("invite not found")
+	}
+
+	return nil
+}
+
+// Consume marks an unused, unexpired invite as used and returns its ID.
+// Invites restricted to an email only match that address.
+func (r *PostgresInviteRepository) Consume(ctx context.Context, codeHash, email string) (string, error) {
+	query := `
+		UPDATE invite_codes
+		SET used_at = $1
+		WHERE code_hash = $2
+		  AND used_at IS NULL
+		  AND (expires_at IS NULL OR expires_at > $1)
+		  AND (email IS NULL OR LOWER(email) = LOWER($3))
+		RETURNING id
+	`
+
+	var inviteID string
+	err := r.db.QueryRow(ctx, query, time.Now(), codeHash, email).Scan(&inviteID)
+
+	if err == pgx.ErrNoRows {
+		return "", fmt.Errorf("invalid or expired invite code")
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("failed to use invite: %w", err)
+	}
+
+	return inviteID, nil
+}
+
+// Release makes a consumed invite usable again, e.g. when registration failed
+func (r *PostgresInviteRepository) Release(ctx context.Context, inviteID string) error {
+	_, err := r.db.Exec(ctx, `UPDATE invite_codes SET used_at = NULL WHERE id = $1 AND used_by IS NULL`, inviteID)
+	if err != nil {
+		return fmt.Errorf("failed to release invite: %w", err)
+	}
+
+	return nil
+}
+
+// AttachUser records which user registered with the invite
+func (r *PostgresInviteRepository) AttachUser(ctx context.Context, inviteID, userID string) error {
+	_, err := r.db.Exec(ctx, `UPDATE invite_codes SET used_by = $1 WHERE id = $2`, userID, inviteID)
+	if err != nil {
+		return fmt.Errorf("failed to update invite: %w", err)
+	}
+
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
+// JWTMiddleware authenticates requests with either a JWT access token or a personal access token
 func JWTMiddleware(service *Service) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
@@ -25,7 +26,38 @@ func JWTMiddleware(service *Service) echo.MiddlewareFunc {
 			}
 
 			token := parts[1]
-			userID, err := service.ValidateAccessToken(token)
+
+			// Personal access tokens carry their own scopes and no session
+			if IsPersonalAccessToken(token) {
+				pat, err := service.ValidatePersonalAccessToken(c.Request().Context(), token)
+				if err != nil {
+					return c.JSON(http.StatusUnauthorized, map[string]string{
+						"error": "invalid or expired token",
+					})
+				}
+
+				user, err := service.GetUserByID(c.Request().Context(), pat.UserID)
+				if err != nil {
+					return c.JSON(http.StatusUnauthorized, map[string]string{
+						"error": "user not found",
+					})
+				}
+
+				if user.DisabledAt != nil {
+					return c.JSON(http.StatusForbidden, map[string]string{
+						"error": "account is disabled",
+					})
+				}
+
+				c.Set("user_id", pat.UserID)
+				c.Set("role", user.Role)
+				c.Set("email_verified", user.EmailVerifiedAt != nil)
+				c.Set("token_scopes", pat.Scopes)
+
+				return next(c)
+			}
+
+			userID, sessionID, err := service.ValidateAccessToken(token)
 			if err != nil {
 				return c.JSON(http.StatusUnauthorized, map[string]string{
 					"error": "invalid or expired token",
@@ -33,14 +65,93 @@ func JWTMiddleware(service *Service) echo.MiddlewareFunc {
 			}
 
 			// Get user to retrieve
-			_, err = service.GetUserByID(c.Request().Context(), userID)
+			user, err := service.GetUserByID(c.Request().Context(), userID)
 			if err != nil {
 				return c.JSON(http.StatusUnauthorized, map[string]string{
 					"error": "user not found",
 				})
 			}
 
+			if user.DisabledAt != nil {
+				return c.JSON(http.StatusForbidden, map[string]string{
+					"error": "account is disabled",
+				})
+			}
+
+			if err := service.CheckSession(c.Request().Context(), userID, sessionID); err != nil {
+				return c.JSON(http.StatusUnauthorized, map[string]string{
+					"error": "session has been revoked or has expired",
+				})
+			}
+
 			c.Set("user_id", userID)
+			c.Set("session_id", sessionID)
+			c.Set("role", user.Role)
+			c.Set("email_verified", user.EmailVerifiedAt != nil)
+
+			return next(c)
+		}
+	}
+}
+
+// RequireScope rejects personal access tokens that were not granted the scope.
+// Requests authenticated with a JWT have full access.
+func RequireScope(scope string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			scopes, ok := c.Get("token_scopes").([]string)
+			if ok && !hasScope(scopes, scope) {
+				return c.JSON(http.StatusForbidden, map[string]string{
+					"error": "token is missing required scope: " + scope,
+				})
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// SessionOnly rejects personal access tokens on routes that manage the account itself
+func SessionOnly() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if _, ok := c.Get("token_scopes").([]string); ok {
+				return c.JSON(http.StatusForbidden, map[string]string{
+					"error": "this endpoint requires a login session",
+				})
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// RequireVerifiedEmail rejects users with an unverified email when the service is
+// configured to require verification
+func RequireVerifiedEmail(service *Service) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			verified, _ := c.Get("email_verified").(bool)
+			if service.cfg.RequireVerifiedEmail && !verified {
+				return c.JSON(http.StatusForbidden, map[string]string{
+					"error": "email address must be verified",
+				})
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// RequireAdmin rejects users without the admin role
+func RequireAdmin() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if role, _ := c.Get("role").(string); role != RoleAdmin {
+				return c.JSON(http.StatusForbidden, map[string]string{
+					"error": "admin role required",
+				})
+			}
 
 			return next(c)
 		}
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresOIDCRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresOIDCRepository(db *pgxpool.Pool) *PostgresOIDCRepository {
+	return &PostgresOIDCRepository{db: db}
+}
+
+func (r *PostgresOIDCRepository) CreateState(ctx context.Context, state *OIDCLoginState) error {
+	query := `
+		INSERT INTO oidc_login_states (state, provider, nonce, code_verifier, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		state.State, state.Provider, state.Nonce, state.CodeVerifier, state.ExpiresAt, state.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create login state: %w", err)
+	}
+
+	// Clean up abandoned logins
+	_, err = r.db.Exec(ctx, `DELETE FROM oidc_login_states WHERE expires_at < $1`, time.Now())
+	if err != nil {
+		fmt.Printf("Warning: failed to delete expired login states: %v\n", err)
+	}
+
+	return nil
+}
+
+// ConsumeState deletes an unexpired login state and returns it, so each state is used once
+func (r *PostgresOIDCRepository) ConsumeState(ctx context.Context, state string) (*OIDCLoginState, error) {
+	query := `
+		DELETE FROM oidc_login_states
+		WHERE state = $1 AND expires_at > $2
+		RETURNING state, provider, nonce, code_verifier, expires_at, created_at
+	`
+
+	var loginState OIDCLoginState
+	err := r.db.QueryRow(ctx, query, state, time.Now()).Scan(
+		&loginState.State,
+		&loginState.Provider,
+		&loginState.Nonce,
+		&loginState.CodeVerifier,
+		&loginState.ExpiresAt,
+		&loginState.CreatedAt,
+	)
+
+	if err == pgx.ErrNoRows {
+		return nil, fmt.Errorf("invalid or expired login state")
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume login state: %w", err)
+	}
+
+	return &loginState, nil
+}
+
+func (r *PostgresOIDCRepository) FindIdentity(ctx context.Context, provider, subject string) (*UserIdentity, error) {
+	query := `
+		SELECT id, user_id, provider, subject, email, created_at, last_login_at
+		FROM user_identities
+		WHERE provider = $1 AND subject = $2
+	`
+
+	var identity UserIdentity
+	err := r.db.QueryRow(ctx, query, provider, subject).Scan(
+		&identity.ID,
+		&identity.UserID,
+		&identity.Provider,
+		&identity.Subject,
+		&identity.Email,
+		&identity.CreatedAt,
+		&identity.LastLoginAt,
+	)
+
+	if err == pgx.ErrNoRows {
+		return nil, fmt.Errorf("identity not found")
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to find identity: %w", err)
+	}
+
+	return &identity, nil
+}
+
+func (r *PostgresOIDCRepository) CreateIdentity(ctx context.Context, identity *UserIdentity) error {
+	query := `
+		INSERT INTO user_identities (id, user_id, provider, subject, email, created_at, last_login_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		identity.ID, identity.UserID, identity.Provider, identity.Subject,
+		identity.Email, identity.CreatedAt, identity.LastLoginAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create identity: %w", err)
+	}
+
+	return nil
+}
+
+// TouchIdentity records a login and keeps the provider's email up to date
+func (r *PostgresOIDCRepository) TouchIdentity(ctx context.Context, identityID, email string) error {
+	query := `
+		UPDATE user_identities
+		SET last_login_at = $1, email = $2
+		WHERE id = $3
+	`
+
+	if _, err := r.db.Exec(ctx, query, time.Now(), email, identityID); err != nil {
+		return fmt.Errorf("failed to update identity: %w", err)
+	}
+
+	return nil
+}
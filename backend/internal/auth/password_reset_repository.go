@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresPasswordResetRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresPasswordResetRepository(db *pgxpool.Pool) *PostgresPasswordResetRepository {
+	return &PostgresPasswordResetRepository{db: db}
+}
+
+func (r *PostgresPasswordResetRepository) Create(ctx context.Context, token *PasswordResetToken) error {
+	query := `
+		INSERT INTO password_reset_tokens (id, user_id, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		token.ID, token.UserID, token.TokenHash, token.ExpiresAt, token.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create password reset token: %w", err)
+	}
+
+	return nil
+}
+
+// Consume marks an unused, unexpired token as used and returns its user ID.
+// Every other outstanding token of the user is invalidated at the same time.
+func (r *PostgresPasswordResetRepository) Consume(ctx context.Context, tokenHash string) (string, error) {
+	// Start transaction
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	now := time.Now()
+
+	var userID string
+	err = tx.QueryRow(ctx, `
+		UPDATE password_reset_tokens
+		SET used_at = $1
+		WHERE token_hash = $2 AND used_at IS NULL AND expires_at > $1
+		RETURNING user_id
+	`, now, tokenHash).Scan(&userID)
+
+	if err == pgx.ErrNoRows {
+		return "", fmt.Errorf("invalid or expired reset token")
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("failed to consume password reset token: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE password_reset_tokens
+		SET used_at = $1
+		WHERE user_id = $2 AND used_at IS NULL
+	`, now, userID)
+	if err != nil {
+		return "", fmt.Errorf("failed to invalidate password reset tokens: %w", err)
+	}
+
+	// Commit transaction
+	if err := tx.Commit(ctx); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return userID, nil
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresPreferencesRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresPreferencesRepository(db *pgxpool.Pool) *PostgresPreferencesRepository {
+	return &PostgresPreferencesRepository{db: db}
+}
+
+// Find returns the user's preferences; users who never saved any get an empty document
+func (r *PostgresPreferencesRepository) Find(ctx context.Context, userID string) (*Preferences, error) {
+	prefs := &Preferences{}
+
+	query := `
+		SELECT page_size, search_language, chat_model, default_visibility
+		FROM user_preferences
+		WHERE user_id = $1
+	`
+
+	err := r.db.QueryRow(ctx, query, userID).Scan(
+		&prefs.PageSize, &prefs.SearchLanguage, &prefs.ChatModel, &prefs.DefaultVisibility,
+	)
+
+	if err == pgx.ErrNoRows {
+		return prefs, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to find preferences: %w", err)
+	}
+
+	return prefs, nil
+}
+
+// Save replaces the user's preferences document
+func (r *PostgresPreferencesRepository) Save(ctx context.Context, userID string, prefs *Preferences) error {
+	query := `
+		INSERT INTO user_preferences (user_id, page_size, search_language, chat_model, default_visibility, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id) DO UPDATE
+		SET page_size = EXCLUDED.page_size,
+		    search_language = EXCLUDED.search_language,
+		    chat_model = EXCLUDED.chat_model,
+		    default_visibility = EXCLUDED.default_visibility,
+		    updated_at = EXCLUDED.updated_at
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		userID, prefs.PageSize, prefs.SearchLanguage, prefs.ChatModel, prefs.DefaultVisibility, time.Now(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save preferences: %w", err)
+	}
+
+	return nil
+}
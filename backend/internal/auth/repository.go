@@ -31,14 +31,14 @@ func (r *PostgresUserRepository) Create(
 	}
 
 	query := `
-		INSERT INTO users (id, email, password_hash, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id, email, created_at, updated_at
+		INSERT INTO users (id, email, password_hash, password_set, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, email, password_set, role, created_at, updated_at
 	`
 
 	err := r.db.QueryRow(ctx, query,
-		user.ID, user.Email, user.PasswordHash, user.CreatedAt, user.UpdatedAt,
-	).Scan(&user.ID, &user.Email, &user.CreatedAt, &user.UpdatedAt)
+		user.ID, user.Email, user.PasswordHash, user.PasswordHash != "", user.CreatedAt, user.UpdatedAt,
+	).Scan(&user.ID, &user.Email, &user.HasPassword, &user.Role, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
@@ -50,13 +50,16 @@ func (r *PostgresUserRepository) FindByEmail(ctx context.Context, email string)
 	user := &User{}
 
 	query := `
-		SELECT id, email, password_hash, created_at, updated_at
+		SELECT id, email, password_hash, password_set, name, avatar_url, timezone, locale,
+		       email_verified_at, role, disabled_at, created_at, updated_at
 		FROM users
 		WHERE email = $1
 	`
 
 	err := r.db.QueryRow(ctx, query, email).Scan(
-		&user.ID, &user.Email, &user.PasswordHash, &user.CreatedAt, &user.UpdatedAt,
+		&user.ID, &user.Email, &user.PasswordHash, &user.HasPassword, &user.Name, &user.AvatarURL,
+		&user.Timezone, &user.Locale, &user.EmailVerifiedAt,
+		&user.Role, &user.DisabledAt, &user.CreatedAt, &user.UpdatedAt,
 	)
 
 	if err == pgx.ErrNoRows {
@@ -74,13 +77,16 @@ func (r *PostgresUserRepository) FindByID(ctx context.Context, id string) (*User
 	user := &User{}
 
 	query := `
-		SELECT id, email, password_hash, created_at, updated_at
+		SELECT id, email, password_hash, password_set, name, avatar_url, timezone, locale,
+		       email_verified_at, role, disabled_at, created_at, updated_at
 		FROM users
 		WHERE id = $1
 	`
 
 	err := r.db.QueryRow(ctx, query, id).Scan(
-		&user.ID, &user.Email, &user.PasswordHash, &user.CreatedAt, &user.UpdatedAt,
+		&user.ID, &user.Email, &user.PasswordHash, &user.HasPassword, &user.Name, &user.AvatarURL,
+		&user.Timezone, &user.Locale, &user.EmailVerifiedAt,
+		&user.Role, &user.DisabledAt, &user.CreatedAt, &user.UpdatedAt,
 	)
 
 	if err == pgx.ErrNoRows {
@@ -93,3 +99,222 @@ func (r *PostgresUserRepository) FindByID(ctx context.Context, id string) (*User
 
 	return user, nil
 }
+
+func (r *PostgresUserRepository) UpdatePassword(ctx context.Context, userID, passwordHash string) error {
+	query := `
+		UPDATE users
+		SET password_hash = $1, password_set = TRUE, updated_at = $2
+		WHERE id = $3
+	`
+
+	result, err := r.db.Exec(ctx, query, passwordHash, time.Now(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	return nil
+}
+
+func (r *PostgresUserRepository) MarkEmailVerified(ctx context.Context, userID string) error {
+	query := `
+		UPDATE users
+		SET email_verified_at = COALESCE(email_verified_at, $1), updated_at = $1
+		WHERE id = $2
+	`
+
+	result, err := r.db.Exec(ctx, query, time.Now(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to verify email: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	return nil
+}
+
+func (r *PostgresUserRepository) SetRole(ctx context.Context, userID, role string) error {
+	query := `
+		UPDATE users
+		SET role = $1, updated_at = $2
+		WHERE id = $3
+	`
+
+	result, err := r.db.Exec(ctx, query, role, time.Now(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to update role: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	return nil
+}
+
+func (r *PostgresUserRepository) SetDisabled(ctx context.Context, userID string, disabled bool) error {
+	query := `
+		UPDATE users
+		SET disabled_at = CASE WHEN $1 THEN COALESCE(disabled_at, $2) END, updated_at = $2
+		WHERE id = $3
+	`
+
+	result, err := r.db.Exec(ctx, query, disabled, time.Now(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	return nil
+}
+
+// UpdateProfile stores the profile fields of the user
+func (r *PostgresUserRepository) UpdateProfile(ctx context.Context, user *User) error {
+	query := `
+		UPDATE users
+		SET name = $1, avatar_url = $2, timezone = $3, locale = $4, updated_at = $5
+		WHERE id = $6
+	`
+
+	user.UpdatedAt = time.Now()
+	result, err := r.db.Exec(ctx, query,
+		user.Name, user.AvatarURL, user.Timezone, user.Locale, user.UpdatedAt, user.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update profile: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	return nil
+}
+
+// ListWithUsage returns a page of users with their note and tag counts
+func (r *PostgresUserRepository) ListWithUsage(
+	ctx context.Context,
+	page, perPage int,
+	search string,
+) ([]AdminUser, int, error) {
+	var total int
+	err := r.db.QueryRow(ctx, `
+		SELECT COUNT(*) FROM users
+		WHERE $1 = '' OR email ILIKE '%' || $1 || '%'
+	`, search).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	query := `
+		SELECT u.id, u.email, u.name, u.email_verified_at, u.role, u.disabled_at, u.created_at, u.updated_at,
+			(SELECT COUNT(*) FROM notes n WHERE n.user_id = u.id),
+			(SELECT COUNT(*) FROM tags t WHERE t.user_id = u.id),
+			(SELECT MAX(s.last_seen_at) FROM sessions s WHERE s.user_id = u.id)
+		FROM users u
+		WHERE $1 = '' OR u.email ILIKE '%' || $1 || '%'
+		ORDER BY u.created_at DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	rows, err := r.db.Query(ctx, query, search, perPage, (page-1)*perPage)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+
+	users := []AdminUser{}
+	for rows.Next() {
+		var user AdminUser
+		err := rows.Scan(
+			&user.ID,
+			&user.Email,
+			&user.Name,
+			&user.EmailVerifiedAt,
+			&user.Role,
+			&user.DisabledAt,
+			&user.CreatedAt,
+			&user.UpdatedAt,
+			&user.NoteCount,
+			&user.TagCount,
+			&user.LastSeenAt,
+		)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+
+	return users, total, nil
+}
+
+// Delete removes the user and everything they own in one transaction. Rows are deleted
+// explicitly (rather than relying on ON DELETE CASCADE alone) so the report can count them.
+func (r *PostgresUserRepository) Delete(ctx context.Context, userID string) (*AccountDeletionReport, error) {
+	// Start transaction
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	report := &AccountDeletionReport{}
+
+	steps := []struct {
+		name  string
+		query string
+		count *int
+	}{
+		{"chat messages", `
+			DELETE FROM chat_messages
+			WHERE conversation_id IN (SELECT id FROM chat_conversations WHERE user_id = $1)
+		`, &report.ChatMessages},
+		{"chat conversations", `DELETE FROM chat_conversations WHERE user_id = $1`, &report.ChatConversations},
+		{"note versions", `
+			DELETE FROM note_versions
+			WHERE note_id IN (SELECT id FROM notes WHERE user_id = $1)
+		`, &report.NoteVersions},
+		{"note links", `
+			DELETE FROM note_links
+			WHERE source_note_id IN (SELECT id FROM notes WHERE user_id = $1)
+			   OR target_note_id IN (SELECT id FROM notes WHERE user_id = $1)
+		`, &report.NoteLinks},
+		{"notes", `DELETE FROM notes WHERE user_id = $1`, &report.Notes},
+		{"tags", `DELETE FROM tags WHERE user_id = $1`, &report.Tags},
+		{"sessions", `DELETE FROM sessions WHERE user_id = $1`, &report.Sessions},
+		{"access tokens", `DELETE FROM personal_access_tokens WHERE user_id = $1`, &report.AccessTokens},
+	}
+
+	for _, step := range steps {
+		result, err := tx.Exec(ctx, step.query, userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to delete %s: %w", step.name, err)
+		}
+		*step.count = int(result.RowsAffected())
+	}
+
+	// Remaining per-user rows (tokens, 2FA, security events) cascade from users
+	result, err := tx.Exec(ctx, `DELETE FROM users WHERE id = $1`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	// Commit transaction
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return report, nil
+}
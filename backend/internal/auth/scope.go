@@ -0,0 +1,27 @@
+package auth
+
+// Scopes that can be granted to personal access tokens
+const (
+	ScopeNotesRead  = "notes:read"
+	ScopeNotesWrite = "notes:write"
+	ScopeChat       = "chat"
+	ScopeSearch     = "search"
+)
+
+// validScopes lists every scope a personal access token may request
+var validScopes = map[string]bool{
+	ScopeNotesRead:  true,
+	ScopeNotesWrite: true,
+	ScopeChat:       true,
+	ScopeSearch:     true,
+}
+
+// hasScope reports whether scope is in scopes
+func hasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
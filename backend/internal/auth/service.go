@@ -2,10 +2,22 @@ package auth
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"math"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/muhammedikinci/yapgan/pkg/jwtkeys"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -15,58 +27,327 @@ type UserRepository interface {
 	Create(ctx context.Context, email, passwordHash string) (*User, error)
 	FindByEmail(ctx context.Context, email string) (*User, error)
 	FindByID(ctx context.Context, id string) (*User, error)
+	UpdatePassword(ctx context.Context, userID, passwordHash string) error
+	MarkEmailVerified(ctx context.Context, userID string) error
+	Delete(ctx context.Context, userID string) (*AccountDeletionReport, error)
+	SetRole(ctx context.Context, userID, role string) error
+	SetDisabled(ctx context.Context, userID string, disabled bool) error
+	ListWithUsage(ctx context.Context, page, perPage int, search string) ([]AdminUser, int, error)
+	UpdateProfile(ctx context.Context, user *User) error
 }
 
+// PreferencesRepository defines what the auth service needs to store user preferences
+type PreferencesRepository interface {
+	Find(ctx context.Context, userID string) (*Preferences, error)
+	Save(ctx context.Context, userID string, prefs *Preferences) error
+}
+
+// InviteRepository defines what the auth service needs to store invite codes
+type InviteRepository interface {
+	Create(ctx context.Context, invite *InviteCode) error
+	List(ctx context.Context) ([]InviteCode, error)
+	Delete(ctx context.Context, inviteID string) error
+	Consume(ctx context.Context, codeHash, email string) (string, error)
+	Release(ctx context.Context, inviteID string) error
+	AttachUser(ctx context.Context, inviteID, userID string) error
+}
+
+// RefreshTokenRepository defines what the auth service needs to track refresh tokens
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, token *RefreshToken) error
+	FindByID(ctx context.Context, id string) (*RefreshToken, error)
+	Rotate(ctx context.Context, oldID string, next *RefreshToken) (bool, error)
+}
+
+// SessionRepository defines what the auth service needs to track login sessions
+type SessionRepository interface {
+	Create(ctx context.Context, session *Session) error
+	FindByID(ctx context.Context, userID, sessionID string) (*Session, error)
+	ListActive(ctx context.Context, userID string) ([]Session, error)
+	Touch(ctx context.Context, sessionID string) error
+	Extend(ctx context.Context, sessionID string, expiresAt time.Time) error
+	Revoke(ctx context.Context, userID, sessionID string) error
+	RevokeAll(ctx context.Context, userID string) (int, error)
+}
+
+// AccessTokenRepository defines what the auth service needs to store personal access tokens
+type AccessTokenRepository interface {
+	Create(ctx context.Context, token *PersonalAccessToken) error
+	FindByHash(ctx context.Context, tokenHash string) (*PersonalAccessToken, error)
+	ListByUser(ctx context.Context, userID string) ([]PersonalAccessToken, error)
+	TouchLastUsed(ctx context.Context, tokenID string) error
+	Revoke(ctx context.Context, userID, tokenID string) error
+}
+
+// PasswordResetRepository defines what the auth service needs to store password reset tokens
+type PasswordResetRepository interface {
+	Create(ctx context.Context, token *PasswordResetToken) error
+	Consume(ctx context.Context, tokenHash string) (string, error)
+}
+
+// EmailVerificationRepository defines what the auth service needs to store email verification tokens
+type EmailVerificationRepository interface {
+	Create(ctx context.Context, token *EmailVerificationToken) error
+	Consume(ctx context.Context, tokenHash string) (string, error)
+}
+
+// TwoFactorRepository defines what the auth service needs to store TOTP secrets and recovery codes
+type TwoFactorRepository interface {
+	SavePending(ctx context.Context, userID, secret string) error
+	Find(ctx context.Context, userID string) (*TOTPSecret, error)
+	IsEnabled(ctx context.Context, userID string) (bool, error)
+	Enable(ctx context.Context, userID string, step int64, recoveryCodeHashes []string) error
+	UseStep(ctx context.Context, userID string, step int64) (bool, error)
+	UseRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error)
+	Delete(ctx context.Context, userID string) error
+}
+
+// ThrottleRepository defines what the auth service needs to count failed attempts and record lockouts
+type ThrottleRepository interface {
+	LockedUntil(ctx context.Context, keys []string) (time.Time, error)
+	Increment(ctx context.Context, key string, window time.Duration) (int, int, error)
+	Lock(ctx context.Context, key string, until time.Time) error
+	Reset(ctx context.Context, key string) error
+	CreateEvent(ctx context.Context, event *SecurityEvent) error
+	ListEvents(ctx context.Context, userID string, limit int) ([]SecurityEvent, error)
+}
+
+// VectorStore defines what the auth service needs to count and purge a user's vectors
+type VectorStore interface {
+	CountUserPoints(ctx context.Context, userID string) (uint64, error)
+	DeleteUserPoints(ctx context.Context, userID string) (uint64, error)
+}
+
+// OIDCRepository defines what the auth service needs to run OpenID Connect logins
+type OIDCRepository interface {
+	CreateState(ctx context.Context, state *OIDCLoginState) error
+	ConsumeState(ctx context.Context, state string) (*OIDCLoginState, error)
+	FindIdentity(ctx context.Context, provider, subject string) (*UserIdentity, error)
+	CreateIdentity(ctx context.Context, identity *UserIdentity) error
+	TouchIdentity(ctx context.Context, identityID, email string) error
+}
+
+// IdentityProvider defines what the auth service needs from an OpenID Connect provider
+type IdentityProvider interface {
+	AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error)
+	Exchange(ctx context.Context, code, codeVerifier, nonce string) (*ExternalIdentity, error)
+}
+
+// OIDCProvider is a configured identity provider users can sign in with
+type OIDCProvider struct {
+	Name        string
+	DisplayName string
+	AllowSignup bool // Create accounts for verified emails that have none yet
+	Client      IdentityProvider
+}
+
+// TokenSigner defines what the auth service needs to sign and verify its JWTs
+type TokenSigner interface {
+	Sign(claims jwt.MapClaims) (string, error)
+	Parse(tokenString string) (jwt.MapClaims, error)
+	JWKS() jwtkeys.JWKSet
+}
+
+// Mailer defines how the auth service sends email
+type Mailer interface {
+	Send(ctx context.Context, to, subject, body string) error
+}
+
+// Config holds the settings of the auth service
+type Config struct {
+	AppBaseURL               string      // Web app URL used for links in emails
+	AccessKeys               TokenSigner // Signs access and two-factor challenge tokens
+	RefreshKeys              TokenSigner // Signs refresh tokens
+	AccessTokenExpiry        time.Duration
+	RefreshTokenExpiry       time.Duration
+	PasswordResetExpiry      time.Duration
+	EmailVerificationExpiry  time.Duration
+	TwoFactorChallengeExpiry time.Duration // Lifetime of the token between password and code steps
+	RequireVerifiedEmail     bool          // Block note-writing routes until the email is verified
+	LoginLockoutThreshold    int           // Failed logins per account before it is locked
+	IPLockoutThreshold       int           // Failed logins per IP before it is locked
+	LockoutBaseDuration      time.Duration
+	LockoutMaxDuration       time.Duration
+	RegistrationLimit        int // Registrations allowed per IP within RegistrationWindow
+	RegistrationWindow       time.Duration
+	RegistrationMode         string   // RegistrationOpen, RegistrationInviteOnly or RegistrationDisabled
+	MaxPageSize              int      // Upper bound for the page size preference
+	SearchLanguages          []string // Text search configurations users may choose
+	ChatModels               []string // Chat models users may choose
+}
+
+// Registration modes
+const (
+	RegistrationOpen       = "open"
+	RegistrationInviteOnly = "invite"
+	RegistrationDisabled   = "disabled"
+)
+
+// minPasswordLength applies to passwords set through reset and change flows
+const minPasswordLength = 8
+
+// accessTokenPrefix marks personal access tokens so they can be told apart from JWTs
+const accessTokenPrefix = "yap_"
+
+// recoveryCodeCount is how many one-time recovery codes are issued when 2FA is enabled
+const recoveryCodeCount = 10
+
+// failureWindow is how long failed attempts count towards a lockout
+const failureWindow = 15 * time.Minute
+
+// lockoutMemory is how long without failures before lockouts stop escalating
+const lockoutMemory = 24 * time.Hour
+
+// securityEventLimit caps how many security events are returned to the user
+const securityEventLimit = 50
+
+// oidcStateExpiry is how long a user has to complete a login at the identity provider
+const oidcStateExpiry = 10 * time.Minute
+
+// oidcReauthWindow is how recent a single sign-on login must be to confirm account
+// deletion for accounts without a password
+const oidcReauthWindow = 10 * time.Minute
+
+// Length limits of profile fields
+const (
+	maxNameLength      = 100
+	maxAvatarURLLength = 2048
+)
+
+// localePattern accepts BCP 47 style language tags such as "en" or "tr-TR"
+var localePattern = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8})*$`)
+
+// Page sizes of the admin user list
+const (
+	adminDefaultPageSize = 20
+	adminMaxPageSize     = 100
+)
+
+// challengeTokenType marks the short-lived JWT issued between the two login steps
+const challengeTokenType = "2fa_challenge"
+
 type Service struct {
-	userRepo           UserRepository
-	jwtSecret          string
-	refreshSecret      string
-	accessTokenExpiry  time.Duration
-	refreshTokenExpiry time.Duration
+	userRepo          UserRepository
+	tokenRepo         RefreshTokenRepository
+	sessionRepo       SessionRepository
+	accessTokenRepo   AccessTokenRepository
+	passwordResetRepo PasswordResetRepository
+	verificationRepo  EmailVerificationRepository
+	twoFactorRepo     TwoFactorRepository
+	throttleRepo      ThrottleRepository
+	vectorStore       VectorStore
+	oidcRepo          OIDCRepository
+	oidcProviders     []OIDCProvider
+	inviteRepo        InviteRepository
+	preferencesRepo   PreferencesRepository
+	mailer            Mailer
+	cfg               Config
 }
 
 func NewService(
 	userRepo UserRepository,
-	jwtSecret, refreshSecret string,
-	accessExpiry, refreshExpiry time.Duration,
+	tokenRepo RefreshTokenRepository,
+	sessionRepo SessionRepository,
+	accessTokenRepo AccessTokenRepository,
+	passwordResetRepo PasswordResetRepository,
+	verificationRepo EmailVerificationRepository,
+	twoFactorRepo TwoFactorRepository,
+	throttleRepo ThrottleRepository,
+	vectorStore VectorStore,
+	oidcRepo OIDCRepository,
+	oidcProviders []OIDCProvider,
+	inviteRepo InviteRepository,
+	preferencesRepo PreferencesRepository,
+	mailer Mailer,
+	cfg Config,
 ) *Service {
+	cfg.AppBaseURL = strings.TrimRight(cfg.AppBaseURL, "/")
+
 	return &Service{
-		userRepo:           userRepo,
-		jwtSecret:          jwtSecret,
-		refreshSecret:      refreshSecret,
-		accessTokenExpiry:  accessExpiry,
-		refreshTokenExpiry: refreshExpiry,
+		userRepo:          userRepo,
+		tokenRepo:         tokenRepo,
+		sessionRepo:       sessionRepo,
+		accessTokenRepo:   accessTokenRepo,
+		passwordResetRepo: passwordResetRepo,
+		verificationRepo:  verificationRepo,
+		twoFactorRepo:     twoFactorRepo,
+		throttleRepo:      throttleRepo,
+		vectorStore:       vectorStore,
+		oidcRepo:          oidcRepo,
+		oidcProviders:     oidcProviders,
+		inviteRepo:        inviteRepo,
+		preferencesRepo:   preferencesRepo,
+		mailer:            mailer,
+		cfg:               cfg,
 	}
 }
 
 func (s *Service) Register(ctx context.Context, req RegisterRequest) (*AuthResponse, error) {
+	// Validate email address
+	if err := validateEmail(req.Email); err != nil {
+		return nil, err
+	}
+
+	// Apply the registration mode before anything reveals whether the email is taken
+	switch s.cfg.RegistrationMode {
+	case RegistrationDisabled:
+		return nil, fmt.Errorf("registration is disabled")
+	case RegistrationInviteOnly:
+		if req.InviteCode == "" {
+			return nil, fmt.Errorf("an invite code is required to register")
+		}
+	}
+
+	// Throttle registrations per IP
+	if err := s.throttleRegistration(ctx, req.Client); err != nil {
+		return nil, err
+	}
+
+	var inviteID string
+	var err error
+	if s.cfg.RegistrationMode == RegistrationInviteOnly {
+		inviteID, err = s.inviteRepo.Consume(ctx, hashToken(strings.TrimSpace(req.InviteCode)), req.Email)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Check if user already exists
 	existingUser, err := s.userRepo.FindByEmail(ctx, req.Email)
 	if err == nil && existingUser != nil {
+		s.releaseInvite(ctx, inviteID)
 		return nil, fmt.Errorf("user already exists")
 	}
 
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
+		s.releaseInvite(ctx, inviteID)
 		return nil, fmt.Errorf("failed to hash password: %w", err)
 	}
 
 	// Create user
 	user, err := s.userRepo.Create(ctx, req.Email, string(hashedPassword))
 	if err != nil {
+		s.releaseInvite(ctx, inviteID)
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
 
-	// Generate tokens
-	accessToken, err := s.generateAccessToken(user.ID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate access token: %w", err)
+	if inviteID != "" {
+		if err := s.inviteRepo.AttachUser(ctx, inviteID, user.ID); err != nil {
+			fmt.Printf("Warning: failed to record invite %s for user %s: %v\n", inviteID, user.ID, err)
+		}
+	}
+
+	// Send verification email (don't fail registration if this fails)
+	if err := s.sendVerificationEmail(ctx, user); err != nil {
+		fmt.Printf("Warning: failed to send verification email to user %s: %v\n", user.ID, err)
 	}
 
-	refreshToken, err := s.generateRefreshToken(user.ID)
+	// Generate tokens
+	accessToken, refreshToken, err := s.GenerateTokensForUser(ctx, user.ID, AuthMethodPassword, req.Client)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+		return nil, err
 	}
 
 	return &AuthResponse{
@@ -77,29 +358,140 @@ func (s *Service) Register(ctx context.Context, req RegisterRequest) (*AuthRespo
 }
 
 func (s *Service) Login(ctx context.Context, req LoginRequest) (*AuthResponse, error) {
+	accountKey := "login:account:" + strings.ToLower(strings.TrimSpace(req.Email))
+	ipKey := "login:ip:" + req.Client.IPAddress
+
+	// Reject attempts while the account or IP is locked out
+	if err := s.checkLocked(ctx, accountKey, ipKey); err != nil {
+		return nil, err
+	}
+
 	// Find user
 	user, err := s.userRepo.FindByEmail(ctx, req.Email)
 	if err != nil {
-		return nil, fmt.Errorf("invalid credentials")
+		return nil, s.loginFailed(ctx, nil, accountKey, ipKey, req.Client)
 	}
 
 	// Verify password
 	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password))
 	if err != nil {
-		return nil, fmt.Errorf("invalid credentials")
+		return nil, s.loginFailed(ctx, user, accountKey, ipKey, req.Client)
+	}
+
+	// A correct password clears the account's failed attempts
+	if err := s.throttleRepo.Reset(ctx, accountKey); err != nil {
+		fmt.Printf("Warning: failed to reset login throttle for user %s: %v\n", user.ID, err)
+	}
+
+	return s.startSession(ctx, user, AuthMethodPassword, req.Client)
+}
+
+// startSession finishes a successful first-factor login: it returns a 2FA challenge if
+// the account has two-factor authentication enabled, and a new session otherwise
+func (s *Service) startSession(
+	ctx context.Context,
+	user *User,
+	authMethod string,
+	client ClientInfo,
+) (*AuthResponse, error) {
+	if user.DisabledAt != nil {
+		return nil, fmt.Errorf("account is disabled")
+	}
+
+	// Ask for a second factor if the account has 2FA enabled
+	enabled, err := s.twoFactorRepo.IsEnabled(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if enabled {
+		challengeToken, err := s.generateChallengeToken(user.ID, authMethod)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate challenge token: %w", err)
+		}
+
+		return &AuthResponse{
+			TwoFactorRequired: true,
+			ChallengeToken:    challengeToken,
+			User:              *user,
+		}, nil
 	}
 
 	// Generate tokens
-	accessToken, err := s.generateAccessToken(user.ID)
+	accessToken, refreshToken, err := s.GenerateTokensForUser(ctx, user.ID, authMethod, client)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate access token: %w", err)
+		return nil, err
+	}
+
+	return &AuthResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		User:         *user,
+	}, nil
+}
+
+// Refresh exchanges a refresh token for a new token pair and rotates the refresh token.
+// Presenting a token that was already rotated or revoked revokes its whole family.
+func (s *Service) Refresh(ctx context.Context, req RefreshRequest) (*AuthResponse, error) {
+	tokenID, err := s.validateRefreshToken(req.RefreshToken)
+	if err != nil {
+		return nil, fmt.Errorf("invalid or expired refresh token")
+	}
+
+	stored, err := s.tokenRepo.FindByID(ctx, tokenID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid or expired refresh token")
+	}
+
+	if stored.RevokedAt != nil || stored.ReplacedBy != nil {
+		// Token reuse: assume it leaked and end the whole session
+		if err := s.sessionRepo.Revoke(ctx, stored.UserID, stored.FamilyID); err != nil {
+			return nil, fmt.Errorf("failed to revoke session: %w", err)
+		}
+		return nil, fmt.Errorf("refresh token has been revoked")
+	}
+
+	session, err := s.sessionRepo.FindByID(ctx, stored.UserID, stored.FamilyID)
+	if err != nil || session.RevokedAt != nil {
+		return nil, fmt.Errorf("session has been revoked")
+	}
+
+	user, err := s.userRepo.FindByID(ctx, stored.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid or expired refresh token")
+	}
+
+	if user.DisabledAt != nil {
+		return nil, fmt.Errorf("account is disabled")
 	}
 
-	refreshToken, err := s.generateRefreshToken(user.ID)
+	next, refreshToken, err := s.generateRefreshToken(user.ID, stored.FamilyID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
 	}
 
+	rotated, err := s.tokenRepo.Rotate(ctx, stored.ID, next)
+	if err != nil {
+		return nil, err
+	}
+
+	if !rotated {
+		// Lost a race against another refresh with the same token
+		if err := s.sessionRepo.Revoke(ctx, stored.UserID, stored.FamilyID); err != nil {
+			return nil, fmt.Errorf("failed to revoke session: %w", err)
+		}
+		return nil, fmt.Errorf("refresh token has been revoked")
+	}
+
+	if err := s.sessionRepo.Extend(ctx, session.ID, next.ExpiresAt); err != nil {
+		return nil, err
+	}
+
+	accessToken, err := s.generateAccessToken(user.ID, stored.FamilyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate access token: %w", err)
+	}
+
 	return &AuthResponse{
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
@@ -107,65 +499,1494 @@ func (s *Service) Login(ctx context.Context, req LoginRequest) (*AuthResponse, e
 	}, nil
 }
 
-func (s *Service) generateAccessToken(userID string) (string, error) {
-	claims := jwt.MapClaims{
-		"user_id": userID,
-		"exp":     time.Now().Add(s.accessTokenExpiry).Unix(),
-		"iat":     time.Now().Unix(),
+// Logout revokes the given session and every refresh token issued for it
+func (s *Service) Logout(ctx context.Context, userID, sessionID string) error {
+	if sessionID == "" {
+		return fmt.Errorf("token is not bound to a session")
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.jwtSecret))
+	return s.sessionRepo.Revoke(ctx, userID, sessionID)
 }
 
-func (s *Service) generateRefreshToken(userID string) (string, error) {
-	claims := jwt.MapClaims{
-		"user_id": userID,
-		"exp":     time.Now().Add(s.refreshTokenExpiry).Unix(),
-		"iat":     time.Now().Unix(),
+// ============================================================
+// Email Verification Methods
+// ============================================================
+
+// VerifyEmail marks the address of the token's user as verified
+func (s *Service) VerifyEmail(ctx context.Context, req VerifyEmailRequest) error {
+	userID, err := s.verificationRepo.Consume(ctx, hashToken(req.Token))
+	if err != nil {
+		return err
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.refreshSecret))
+	return s.userRepo.MarkEmailVerified(ctx, userID)
 }
 
-func (s *Service) ValidateAccessToken(tokenString string) (string, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(s.jwtSecret), nil
-	})
+// ResendVerificationEmail sends a new verification link to a user whose email is unverified
+func (s *Service) ResendVerificationEmail(ctx context.Context, userID string) error {
+	user, err := s.userRepo.FindByID(ctx, userID)
 	if err != nil {
-		return "", fmt.Errorf("invalid token: %w", err)
+		return err
 	}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		userID, ok := claims["user_id"].(string)
-		if !ok {
-			return "", fmt.Errorf("invalid token claims")
-		}
-		return userID, nil
+	if user.EmailVerifiedAt != nil {
+		return fmt.Errorf("email is already verified")
 	}
 
-	return "", fmt.Errorf("invalid token")
+	return s.sendVerificationEmail(ctx, user)
 }
 
-func (s *Service) GetUserByID(ctx context.Context, userID string) (*User, error) {
-	return s.userRepo.FindByID(ctx, userID)
+// sendVerificationEmail stores a new verification token and emails its link
+func (s *Service) sendVerificationEmail(ctx context.Context, user *User) error {
+	plain, err := generateOpaqueToken()
+	if err != nil {
+		return fmt.Errorf("failed to generate verification token: %w", err)
+	}
+
+	now := time.Now()
+	token := &EmailVerificationToken{
+		ID:        uuid.New().String(),
+		UserID:    user.ID,
+		TokenHash: hashToken(plain),
+		ExpiresAt: now.Add(s.cfg.EmailVerificationExpiry),
+		CreatedAt: now,
+	}
+
+	if err := s.verificationRepo.Create(ctx, token); err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/verify-email?token=%s", s.cfg.AppBaseURL, plain)
+	body := fmt.Sprintf(
+		"Welcome to Yapgan!\n\n"+
+			"Open this link to confirm your email address:\n%s\n\n"+
+			"The link expires in %s.\n",
+		link,
+		s.cfg.EmailVerificationExpiry,
+	)
+
+	return s.mailer.Send(ctx, user.Email, "Confirm your Yapgan email address", body)
 }
 
-func (s *Service) GenerateTokensForUser(
+// validateEmail checks that the input is a bare email address
+func validateEmail(email string) error {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return fmt.Errorf("invalid email address")
+	}
+	return nil
+}
+
+// ============================================================
+// Password Methods
+// ============================================================
+
+// ForgotPassword emails a password reset link if the address belongs to an account.
+// It never reports whether the account exists.
+func (s *Service) ForgotPassword(ctx context.Context, req ForgotPasswordRequest) error {
+	user, err := s.userRepo.FindByEmail(ctx, strings.TrimSpace(req.Email))
+	if err != nil {
+		return nil
+	}
+
+	plain, err := generateOpaqueToken()
+	if err != nil {
+		return fmt.Errorf("failed to generate reset token: %w", err)
+	}
+
+	now := time.Now()
+	token := &PasswordResetToken{
+		ID:        uuid.New().String(),
+		UserID:    user.ID,
+		TokenHash: hashToken(plain),
+		ExpiresAt: now.Add(s.cfg.PasswordResetExpiry),
+		CreatedAt: now,
+	}
+
+	if err := s.passwordResetRepo.Create(ctx, token); err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", s.cfg.AppBaseURL, plain)
+	body := fmt.Sprintf(
+		"Someone asked to reset the password of your Yapgan account.\n\n"+
+			"Open this link to choose a new password:\n%s\n\n"+
+			"The link expires in %s and can only be used once.\n"+
+			"If you didn't ask for this, you can ignore this email.\n",
+		link,
+		s.cfg.PasswordResetExpiry,
+	)
+
+	if err := s.mailer.Send(ctx, user.Email, "Reset your Yapgan password", body); err != nil {
+		// Log error but don't reveal it to the caller
+		fmt.Printf("Warning: failed to send password reset email to user %s: %v\n", user.ID, err)
+	}
+
+	return nil
+}
+
+// ResetPassword sets a new password using a reset token and ends every session
+func (s *Service) ResetPassword(ctx context.Context, req ResetPasswordRequest) error {
+	if err := validatePassword(req.NewPassword); err != nil {
+		return err
+	}
+
+	userID, err := s.passwordResetRepo.Consume(ctx, hashToken(req.Token))
+	if err != nil {
+		return err
+	}
+
+	if err := s.setPassword(ctx, userID, req.NewPassword); err != nil {
+		return err
+	}
+
+	if _, err := s.sessionRepo.RevokeAll(ctx, userID); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	return nil
+}
+
+// ChangePassword replaces the password of a logged-in user, ends every existing
+// session and starts a new one for the caller
+func (s *Service) ChangePassword(
+	ctx context.Context,
 	userID string,
-) (accessToken, refreshToken string, err error) {
-	accessToken, err = s.generateAccessToken(userID)
+	req ChangePasswordRequest,
+) (*AuthResponse, error) {
+	user, err := s.userRepo.FindByID(ctx, userID)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to generate access token: %w", err)
+		return nil, err
+	}
+
+	if !user.HasPassword {
+		return nil, fmt.Errorf("no password set; use password reset to set one")
 	}
 
-	refreshToken, err = s.generateRefreshToken(userID)
+	if req.CurrentPassword == "" {
+		return nil, fmt.Errorf("current_password is required")
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.CurrentPassword))
 	if err != nil {
-		return "", "", fmt.Errorf("failed to generate refresh token: %w", err)
+		return nil, fmt.Errorf("current password is incorrect")
+	}
+
+	if err := validatePassword(req.NewPassword); err != nil {
+		return nil, err
+	}
+
+	if err := s.setPassword(ctx, userID, req.NewPassword); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.sessionRepo.RevokeAll(ctx, userID); err != nil {
+		return nil, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	accessToken, refreshToken, err := s.GenerateTokensForUser(ctx, userID, AuthMethodPassword, req.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		User:         *user,
+	}, nil
+}
+
+// setPassword hashes and stores a new password
+func (s *Service) setPassword(ctx context.Context, userID, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	return s.userRepo.UpdatePassword(ctx, userID, string(hashedPassword))
+}
+
+// validatePassword checks the rules for newly chosen passwords
+func validatePassword(password string) error {
+	if len(password) < minPasswordLength {
+		return fmt.Errorf("password must be at least %d characters long", minPasswordLength)
+	}
+	return nil
+}
+
+// ============================================================
+// Two-Factor Methods
+// ============================================================
+
+// EnrollTOTP creates a pending TOTP secret. It only takes effect once ConfirmTOTP
+// has seen a valid code from the authenticator app.
+func (s *Service) EnrollTOTP(ctx context.Context, userID string) (*EnrollTOTPResponse, error) {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate totp secret: %w", err)
+	}
+
+	if err := s.twoFactorRepo.SavePending(ctx, userID, secret); err != nil {
+		return nil, err
+	}
+
+	return &EnrollTOTPResponse{
+		Secret:     secret,
+		OTPAuthURI: totpURI(user.Email, secret),
+	}, nil
+}
+
+// ConfirmTOTP enables 2FA after checking a code and returns fresh recovery codes
+func (s *Service) ConfirmTOTP(
+	ctx context.Context,
+	userID string,
+	req ConfirmTOTPRequest,
+) (*RecoveryCodesResponse, error) {
+	enrollment, err := s.twoFactorRepo.Find(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if enrollment.EnabledAt != nil {
+		return nil, fmt.Errorf("two-factor authentication is already enabled")
+	}
+
+	step, ok := verifyTOTP(enrollment.Secret, req.Code, time.Now())
+	if !ok {
+		return nil, fmt.Errorf("invalid two-factor code")
+	}
+
+	codes, err := generateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate recovery codes: %w", err)
+	}
+
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = hashToken(normalizeRecoveryCode(code))
+	}
+
+	if err := s.twoFactorRepo.Enable(ctx, userID, step, hashes); err != nil {
+		return nil, err
+	}
+
+	return &RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// VerifyTwoFactor completes a login started by Login and starts a new session
+func (s *Service) VerifyTwoFactor(ctx context.Context, req VerifyTwoFactorRequest) (*AuthResponse, error) {
+	userID, authMethod, err := s.validateChallengeToken(req.ChallengeToken)
+	if err != nil {
+		return nil, fmt.Errorf("invalid or expired challenge token")
+	}
+
+	// Codes are only 6 digits, so guesses are throttled like passwords
+	throttleKey := "2fa:user:" + userID
+	if err := s.checkLocked(ctx, throttleKey); err != nil {
+		return nil, err
+	}
+
+	if err := s.verifySecondFactor(ctx, userID, req.Code, req.RecoveryCode); err != nil {
+		lockedUntil, throttleErr := s.recordFailure(ctx, throttleKey, s.cfg.LoginLockoutThreshold)
+		if throttleErr != nil {
+			return nil, throttleErr
+		}
+		if !lockedUntil.IsZero() {
+			s.recordSecurityEvent(ctx, userID, "two_factor_locked", req.Client, lockedUntil)
+			return nil, &LockedError{RetryAfter: time.Until(lockedUntil)}
+		}
+		return nil, err
+	}
+
+	if err := s.throttleRepo.Reset(ctx, throttleKey); err != nil {
+		fmt.Printf("Warning: failed to reset 2fa throttle for user %s: %v\n", userID, err)
+	}
+
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.DisabledAt != nil {
+		return nil, fmt.Errorf("account is disabled")
+	}
+
+	accessToken, refreshToken, err := s.GenerateTokensForUser(ctx, userID, authMethod, req.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		User:         *user,
+	}, nil
+}
+
+// DisableTOTP turns 2FA off after re-authenticating with the password (or a recent
+// single sign-on login for accounts without one) and a second factor
+func (s *Service) DisableTOTP(ctx context.Context, userID, sessionID string, req DisableTOTPRequest) error {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.confirmIdentity(ctx, user, sessionID, req.Password); err != nil {
+		return err
+	}
+
+	if err := s.verifySecondFactor(ctx, userID, req.Code, req.RecoveryCode); err != nil {
+		return err
+	}
+
+	return s.twoFactorRepo.Delete(ctx, userID)
+}
+
+// verifySecondFactor accepts either a current TOTP code or an unused recovery code.
+// Both are single-use: accepted time steps and recovery codes are recorded.
+func (s *Service) verifySecondFactor(ctx context.Context, userID, code, recoveryCode string) error {
+	enrollment, err := s.twoFactorRepo.Find(ctx, userID)
+	if err != nil || enrollment.EnabledAt == nil {
+		return fmt.Errorf("two-factor authentication is not enabled")
+	}
+
+	if recoveryCode != "" {
+		used, err := s.twoFactorRepo.UseRecoveryCode(ctx, userID, hashToken(normalizeRecoveryCode(recoveryCode)))
+		if err != nil {
+			return err
+		}
+		if !used {
+			return fmt.Errorf("invalid recovery code")
+		}
+		return nil
+	}
+
+	step, ok := verifyTOTP(enrollment.Secret, code, time.Now())
+	if !ok {
+		return fmt.Errorf("invalid two-factor code")
+	}
+
+	fresh, err := s.twoFactorRepo.UseStep(ctx, userID, step)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return fmt.Errorf("two-factor code was already used")
+	}
+
+	return nil
+}
+
+// confirmIdentity re-authenticates the user before a sensitive change: with the password,
+// or for accounts without one, with a session from a recent single sign-on login
+func (s *Service) confirmIdentity(ctx context.Context, user *User, sessionID, password string) error {
+	if !user.HasPassword {
+		session, err := s.sessionRepo.FindByID(ctx, user.ID, sessionID)
+		if err != nil {
+			return err
+		}
+
+		if session.AuthMethod != AuthMethodOIDC || time.Since(session.CreatedAt) > oidcReauthWindow {
+			return fmt.Errorf("sign in with your identity provider again to confirm this change")
+		}
+		return nil
+	}
+
+	if password == "" {
+		return fmt.Errorf("password is required")
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return fmt.Errorf("password is incorrect")
+	}
+	return nil
+}
+
+// ============================================================
+// OIDC Methods
+// ============================================================
+
+// ListOIDCProviders returns the identity providers users can sign in with
+func (s *Service) ListOIDCProviders() *ListOIDCProvidersResponse {
+	providers := make([]OIDCProviderInfo, 0, len(s.oidcProviders))
+	for _, provider := range s.oidcProviders {
+		providers = append(providers, OIDCProviderInfo{
+			Name:        provider.Name,
+			DisplayName: provider.DisplayName,
+		})
+	}
+
+	return &ListOIDCProvidersResponse{Providers: providers}
+}
+
+// StartOIDCLogin stores a new state, nonce and PKCE verifier and returns the URL
+// to send the user to
+func (s *Service) StartOIDCLogin(ctx context.Context, providerName string) (*StartOIDCLoginResponse, error) {
+	provider, err := s.findOIDCProvider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	loginState := &OIDCLoginState{
+		Provider:  provider.Name,
+		ExpiresAt: now.Add(oidcStateExpiry),
+		CreatedAt: now,
+	}
+
+	for _, value := range []*string{&loginState.State, &loginState.Nonce, &loginState.CodeVerifier} {
+		if *value, err = generateOpaqueToken(); err != nil {
+			return nil, fmt.Errorf("failed to generate login state: %w", err)
+		}
+	}
+
+	if err := s.oidcRepo.CreateState(ctx, loginState); err != nil {
+		return nil, err
+	}
+
+	authURL, err := provider.Client.AuthCodeURL(
+		ctx,
+		loginState.State,
+		loginState.Nonce,
+		pkceChallenge(loginState.CodeVerifier),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build authorization url: %w", err)
+	}
+
+	return &StartOIDCLoginResponse{
+		AuthorizationURL: authURL,
+		State:            loginState.State,
+	}, nil
+}
+
+// CompleteOIDCLogin exchanges the authorization code, finds or links the user and
+// starts a session exactly like a password login
+func (s *Service) CompleteOIDCLogin(
+	ctx context.Context,
+	providerName string,
+	req OIDCCallbackRequest,
+) (*AuthResponse, error) {
+	provider, err := s.findOIDCProvider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	loginState, err := s.oidcRepo.ConsumeState(ctx, req.State)
+	if err != nil {
+		return nil, err
+	}
+
+	if loginState.Provider != provider.Name {
+		return nil, fmt.Errorf("invalid or expired login state")
+	}
+
+	identity, err := provider.Client.Exchange(ctx, req.Code, loginState.CodeVerifier, loginState.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign in with %s: %w", provider.DisplayName, err)
+	}
+
+	user, err := s.resolveOIDCUser(ctx, provider, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.startSession(ctx, user, AuthMethodOIDC, req.Client)
+}
+
+// resolveOIDCUser returns the user linked to the identity. Unlinked identities are
+// linked to the account with the same verified email, or get a new account if the
+// provider allows sign-up.
+func (s *Service) resolveOIDCUser(
+	ctx context.Context,
+	provider *OIDCProvider,
+	identity *ExternalIdentity,
+) (*User, error) {
+	linked, err := s.oidcRepo.FindIdentity(ctx, provider.Name, identity.Subject)
+	if err == nil {
+		if err := s.oidcRepo.TouchIdentity(ctx, linked.ID, identity.Email); err != nil {
+			fmt.Printf("Warning: failed to update identity %s: %v\n", linked.ID, err)
+		}
+		return s.userRepo.FindByID(ctx, linked.UserID)
+	}
+
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, fmt.Errorf("%s did not provide a verified email address", provider.DisplayName)
+	}
+
+	user, err := s.userRepo.FindByEmail(ctx, identity.Email)
+	if err != nil {
+		if !provider.AllowSignup {
+			return nil, fmt.Errorf("no account exists for %s", identity.Email)
+		}
+		if user, err = s.createExternalUser(ctx, identity.Email); err != nil {
+			return nil, err
+		}
+	} else if user.EmailVerifiedAt == nil {
+		// Linking to an unverified account would let whoever registered the address
+		// first take over the provider's user
+		return nil, fmt.Errorf("verify your email address before signing in with %s", provider.DisplayName)
+	}
+
+	now := time.Now()
+	err = s.oidcRepo.CreateIdentity(ctx, &UserIdentity{
+		ID:          uuid.New().String(),
+		UserID:      user.ID,
+		Provider:    provider.Name,
+		Subject:     identity.Subject,
+		Email:       identity.Email,
+		CreatedAt:   now,
+		LastLoginAt: now,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// createExternalUser creates a verified account for a provider's user. The account has no
+// password, so password logins fail; a password can be set later through the reset flow.
+func (s *Service) createExternalUser(ctx context.Context, email string) (*User, error) {
+	if s.cfg.RegistrationMode != RegistrationOpen {
+		return nil, fmt.Errorf("no account exists for %s and registration is closed", email)
+	}
+
+	user, err := s.userRepo.Create(ctx, email, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	if err := s.userRepo.MarkEmailVerified(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	user.EmailVerifiedAt = &now
+
+	return user, nil
+}
+
+func (s *Service) findOIDCProvider(name string) (*OIDCProvider, error) {
+	for i := range s.oidcProviders {
+		if s.oidcProviders[i].Name == name {
+			return &s.oidcProviders[i], nil
+		}
+	}
+	return nil, fmt.Errorf("unknown identity provider: %s", name)
+}
+
+// pkceChallenge derives the S256 code challenge from a PKCE code verifier
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// ============================================================
+// Profile Methods
+// ============================================================
+
+// GetProfile returns the user's profile together with their preferences
+func (s *Service) GetProfile(ctx context.Context, userID string) (*ProfileResponse, error) {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	prefs, err := s.preferencesRepo.Find(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ProfileResponse{
+		User:        *user,
+		Preferences: *prefs,
+	}, nil
+}
+
+// UpdateProfile applies the fields present in the request to the profile and,
+// when given, the preferences document
+func (s *Service) UpdateProfile(
+	ctx context.Context,
+	userID string,
+	req UpdateProfileRequest,
+) (*ProfileResponse, error) {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if len([]rune(name)) > maxNameLength {
+			return nil, fmt.Errorf("name must be at most %d characters", maxNameLength)
+		}
+		user.Name = optionalString(name)
+	}
+
+	if req.AvatarURL != nil {
+		avatarURL := strings.TrimSpace(*req.AvatarURL)
+		if err := validateAvatarURL(avatarURL); err != nil {
+			return nil, err
+		}
+		user.AvatarURL = optionalString(avatarURL)
+	}
+
+	if req.Timezone != nil {
+		timezone := strings.TrimSpace(*req.Timezone)
+		if timezone != "" {
+			if _, err := time.LoadLocation(timezone); err != nil || timezone == "Local" {
+				return nil, fmt.Errorf("unknown timezone %q", timezone)
+			}
+		}
+		user.Timezone = optionalString(timezone)
+	}
+
+	if req.Locale != nil {
+		locale := strings.TrimSpace(*req.Locale)
+		if locale != "" && !localePattern.MatchString(locale) {
+			return nil, fmt.Errorf("invalid locale %q", locale)
+		}
+		user.Locale = optionalString(locale)
+	}
+
+	// Validate preferences before storing anything
+	var prefs *Preferences
+	if req.Preferences != nil {
+		prefs, err = s.mergePreferences(ctx, userID, *req.Preferences)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.userRepo.UpdateProfile(ctx, user); err != nil {
+		return nil, err
+	}
+
+	if prefs == nil {
+		prefs, err = s.preferencesRepo.Find(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+	} else if err := s.preferencesRepo.Save(ctx, userID, prefs); err != nil {
+		return nil, err
+	}
+
+	return &ProfileResponse{
+		User:        *user,
+		Preferences: *prefs,
+	}, nil
+}
+
+// GetPreferences returns the user's preferences document
+func (s *Service) GetPreferences(ctx context.Context, userID string) (*Preferences, error) {
+	return s.preferencesRepo.Find(ctx, userID)
+}
+
+// UpdatePreferences applies the preferences present in the request
+func (s *Service) UpdatePreferences(
+	ctx context.Context,
+	userID string,
+	req UpdatePreferencesRequest,
+) (*Preferences, error) {
+	prefs, err := s.mergePreferences(ctx, userID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.preferencesRepo.Save(ctx, userID, prefs); err != nil {
+		return nil, err
+	}
+
+	return prefs, nil
+}
+
+// mergePreferences validates the request and applies it to the stored preferences
+func (s *Service) mergePreferences(
+	ctx context.Context,
+	userID string,
+	req UpdatePreferencesRequest,
+) (*Preferences, error) {
+	prefs, err := s.preferencesRepo.Find(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.PageSize != nil {
+		if *req.PageSize < 0 || *req.PageSize > s.cfg.MaxPageSize {
+			return nil, fmt.Errorf("page_size must be between 1 and %d, or 0 to reset it", s.cfg.MaxPageSize)
+		}
+		prefs.PageSize = req.PageSize
+		if *req.PageSize == 0 {
+			prefs.PageSize = nil
+		}
+	}
+
+	if req.SearchLanguage != nil {
+		language := strings.ToLower(strings.TrimSpace(*req.SearchLanguage))
+		if language != "" && !slices.Contains(s.cfg.SearchLanguages, language) {
+			return nil, fmt.Errorf("unsupported search_language %q", language)
+		}
+		prefs.SearchLanguage = optionalString(language)
+	}
+
+	if req.ChatModel != nil {
+		model := strings.TrimSpace(*req.ChatModel)
+		if model != "" && !slices.Contains(s.cfg.ChatModels, model) {
+			return nil, fmt.Errorf("unsupported chat_model %q", model)
+		}
+		prefs.ChatModel = optionalString(model)
+	}
+
+	if req.DefaultVisibility != nil {
+		visibility := strings.TrimSpace(*req.DefaultVisibility)
+		if visibility != "" && visibility != VisibilityPrivate && visibility != VisibilityPublic {
+			return nil, fmt.Errorf("default_visibility must be %q or %q", VisibilityPrivate, VisibilityPublic)
+		}
+		prefs.DefaultVisibility = optionalString(visibility)
+	}
+
+	return prefs, nil
+}
+
+// validateAvatarURL accepts empty values and absolute http(s) URLs
+func validateAvatarURL(avatarURL string) error {
+	if avatarURL == "" {
+		return nil
+	}
+
+	if len(avatarURL) > maxAvatarURLLength {
+		return fmt.Errorf("avatar_url must be at most %d characters", maxAvatarURLLength)
+	}
+
+	parsed, err := url.Parse(avatarURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("avatar_url must be an http or https URL")
+	}
+
+	return nil
+}
+
+// optionalString maps empty strings to nil so they are stored as NULL
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+
+	return &value
+}
+
+// ============================================================
+// Admin Methods
+// ============================================================
+
+// ListUsers returns a page of users with their note, tag and vector counts
+func (s *Service) ListUsers(ctx context.Context, req ListUsersRequest) (*ListUsersResponse, error) {
+	if req.Page < 1 {
+		req.Page = 1
+	}
+	if req.PerPage < 1 {
+		req.PerPage = adminDefaultPageSize
+	}
+	if req.PerPage > adminMaxPageSize {
+		req.PerPage = adminMaxPageSize
+	}
+
+	users, total, err := s.userRepo.ListWithUsage(ctx, req.Page, req.PerPage, req.Search)
+	if err != nil {
+		return nil, err
+	}
+
+	// Vector counts live in Qdrant, not Postgres
+	for i := range users {
+		count, err := s.vectorStore.CountUserPoints(ctx, users[i].ID)
+		if err != nil {
+			fmt.Printf("Warning: failed to count vectors for user %s: %v\n", users[i].ID, err)
+			continue
+		}
+		users[i].VectorCount = count
+	}
+
+	totalPages := int(math.Ceil(float64(total) / float64(req.PerPage)))
+
+	return &ListUsersResponse{
+		Users:      users,
+		Total:      total,
+		Page:       req.Page,
+		PerPage:    req.PerPage,
+		TotalPages: totalPages,
+	}, nil
+}
+
+// SetUserDisabled disables or re-enables an account. Disabling also ends all of its sessions.
+func (s *Service) SetUserDisabled(ctx context.Context, adminID, userID string, disabled bool) error {
+	if disabled && adminID == userID {
+		return fmt.Errorf("you cannot disable your own account")
+	}
+
+	if err := s.userRepo.SetDisabled(ctx, userID, disabled); err != nil {
+		return err
+	}
+
+	if disabled {
+		if _, err := s.sessionRepo.RevokeAll(ctx, userID); err != nil {
+			return fmt.Errorf("failed to revoke sessions: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// SetUserRole changes the role of a user
+func (s *Service) SetUserRole(ctx context.Context, adminID, userID string, req SetUserRoleRequest) error {
+	if req.Role != RoleUser && req.Role != RoleAdmin {
+		return fmt.Errorf("role must be %q or %q", RoleUser, RoleAdmin)
+	}
+
+	if adminID == userID && req.Role != RoleAdmin {
+		return fmt.Errorf("you cannot remove your own admin role")
+	}
+
+	return s.userRepo.SetRole(ctx, userID, req.Role)
+}
+
+// ForceLogout ends every session of a user and returns how many were ended
+func (s *Service) ForceLogout(ctx context.Context, userID string) (int, error) {
+	if _, err := s.userRepo.FindByID(ctx, userID); err != nil {
+		return 0, err
+	}
+
+	return s.RevokeAllSessions(ctx, userID)
+}
+
+// PromoteAdmins gives the admin role to the existing users with the given emails
+func (s *Service) PromoteAdmins(ctx context.Context, emails []string) error {
+	for _, email := range emails {
+		user, err := s.userRepo.FindByEmail(ctx, email)
+		if err != nil {
+			fmt.Printf("Warning: admin %s has no account yet\n", email)
+			continue
+		}
+
+		if user.Role == RoleAdmin {
+			continue
+		}
+
+		if err := s.userRepo.SetRole(ctx, user.ID, RoleAdmin); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CreateInvite generates an invite code for invite-only registration
+func (s *Service) CreateInvite(
+	ctx context.Context,
+	adminID string,
+	req CreateInviteRequest,
+) (*CreateInviteResponse, error) {
+	if req.ExpiresInDays < 0 {
+		return nil, fmt.Errorf("expires_in_days must not be negative")
+	}
+
+	code, err := generateOpaqueToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate invite code: %w", err)
+	}
+
+	now := time.Now()
+	invite := &InviteCode{
+		ID:        uuid.New().String(),
+		CodeHash:  hashToken(code),
+		CreatedBy: adminID,
+		CreatedAt: now,
+	}
+
+	if req.Email != "" {
+		if err := validateEmail(req.Email); err != nil {
+			return nil, err
+		}
+		invite.Email = &req.Email
+	}
+
+	if req.ExpiresInDays > 0 {
+		expiresAt := now.AddDate(0, 0, req.ExpiresInDays)
+		invite.ExpiresAt = &expiresAt
+	}
+
+	if err := s.inviteRepo.Create(ctx, invite); err != nil {
+		return nil, err
+	}
+
+	return &CreateInviteResponse{
+		Code:   code,
+		Invite: *invite,
+	}, nil
+}
+
+func (s *Service) ListInvites(ctx context.Context) (*ListInvitesResponse, error) {
+	invites, err := s.inviteRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ListInvitesResponse{
+		Invites: invites,
+		Total:   len(invites),
+	}, nil
+}
+
+func (s *Service) DeleteInvite(ctx context.Context, inviteID string) error {
+	return s.inviteRepo.Delete(ctx, inviteID)
+}
+
+// releaseInvite gives back an invite consumed by a registration that failed
+func (s *Service) releaseInvite(ctx context.Context, inviteID string) {
+	if inviteID == "" {
+		return
+	}
+
+	if err := s.inviteRepo.Release(ctx, inviteID); err != nil {
+		fmt.Printf("Warning: failed to release invite %s: %v\n", inviteID, err)
+	}
+}
+
+// ============================================================
+// Throttle Methods
+// ============================================================
+
+// checkLocked returns a LockedError if any of the keys is currently locked out
+func (s *Service) checkLocked(ctx context.Context, keys ...string) error {
+	lockedUntil, err := s.throttleRepo.LockedUntil(ctx, keys)
+	if err != nil {
+		return err
+	}
+
+	if !lockedUntil.IsZero() {
+		return &LockedError{RetryAfter: time.Until(lockedUntil)}
+	}
+
+	return nil
+}
+
+// recordFailure counts a failed attempt and locks the key once threshold is reached.
+// Every consecutive lockout doubles the duration, up to LockoutMaxDuration.
+// It returns the lock expiry, or the zero time if the key was not locked.
+func (s *Service) recordFailure(ctx context.Context, key string, threshold int) (time.Time, error) {
+	failures, lockouts, err := s.throttleRepo.Increment(ctx, key, failureWindow)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if failures < threshold {
+		return time.Time{}, nil
+	}
+
+	lockedUntil := time.Now().Add(lockoutDuration(s.cfg.LockoutBaseDuration, s.cfg.LockoutMaxDuration, lockouts))
+	if err := s.throttleRepo.Lock(ctx, key, lockedUntil); err != nil {
+		return time.Time{}, err
+	}
+
+	return lockedUntil, nil
+}
+
+// lockoutDuration returns base doubled once per previous lockout, capped at max
+func lockoutDuration(base, max time.Duration, lockouts int) time.Duration {
+	duration := base
+	for i := 0; i < lockouts && duration < max; i++ {
+		duration *= 2
+	}
+	if duration > max {
+		duration = max
+	}
+	return duration
+}
+
+// loginFailed records a failed login against the account and the IP. Unknown emails
+// are counted too, so lockouts don't reveal which accounts exist.
+func (s *Service) loginFailed(
+	ctx context.Context,
+	user *User,
+	accountKey, ipKey string,
+	client ClientInfo,
+) error {
+	accountLockedUntil, err := s.recordFailure(ctx, accountKey, s.cfg.LoginLockoutThreshold)
+	if err != nil {
+		return err
+	}
+
+	ipLockedUntil, err := s.recordFailure(ctx, ipKey, s.cfg.IPLockoutThreshold)
+	if err != nil {
+		return err
+	}
+
+	if !accountLockedUntil.IsZero() && user != nil {
+		s.recordSecurityEvent(ctx, user.ID, "login_locked", client, accountLockedUntil)
+	}
+
+	lockedUntil := accountLockedUntil
+	if ipLockedUntil.After(lockedUntil) {
+		lockedUntil = ipLockedUntil
+	}
+
+	if !lockedUntil.IsZero() {
+		return &LockedError{RetryAfter: time.Until(lockedUntil)}
+	}
+
+	return fmt.Errorf("invalid credentials")
+}
+
+// throttleRegistration allows RegistrationLimit registrations per IP within RegistrationWindow
+func (s *Service) throttleRegistration(ctx context.Context, client ClientInfo) error {
+	key := "register:ip:" + client.IPAddress
+
+	if err := s.checkLocked(ctx, key); err != nil {
+		return err
+	}
+
+	attempts, _, err := s.throttleRepo.Increment(ctx, key, s.cfg.RegistrationWindow)
+	if err != nil {
+		return err
+	}
+
+	if attempts > s.cfg.RegistrationLimit {
+		lockedUntil := time.Now().Add(s.cfg.RegistrationWindow)
+		if err := s.throttleRepo.Lock(ctx, key, lockedUntil); err != nil {
+			return err
+		}
+		return &LockedError{RetryAfter: s.cfg.RegistrationWindow}
+	}
+
+	return nil
+}
+
+// recordSecurityEvent stores a lockout so the user can see it
+func (s *Service) recordSecurityEvent(
+	ctx context.Context,
+	userID, eventType string,
+	client ClientInfo,
+	lockedUntil time.Time,
+) {
+	event := &SecurityEvent{
+		ID:          uuid.New().String(),
+		UserID:      userID,
+		Type:        eventType,
+		IPAddress:   client.IPAddress,
+		UserAgent:   client.UserAgent,
+		LockedUntil: &lockedUntil,
+		CreatedAt:   time.Now(),
+	}
+
+	if err := s.throttleRepo.CreateEvent(ctx, event); err != nil {
+		fmt.Printf("Warning: failed to record security event for user %s: %v\n", userID, err)
+	}
+}
+
+// ListSecurityEvents returns the most recent security events of the user
+func (s *Service) ListSecurityEvents(ctx context.Context, userID string) (*ListSecurityEventsResponse, error) {
+	events, err := s.throttleRepo.ListEvents(ctx, userID, securityEventLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ListSecurityEventsResponse{
+		Events: events,
+		Total:  len(events),
+	}, nil
+}
+
+// ============================================================
+// Session Methods
+// ============================================================
+
+// CheckSession verifies that a session is still active and records activity on it
+func (s *Service) CheckSession(ctx context.Context, userID, sessionID string) error {
+	session, err := s.sessionRepo.FindByID(ctx, userID, sessionID)
+	if err != nil {
+		return err
+	}
+
+	if session.RevokedAt != nil {
+		return fmt.Errorf("session has been revoked")
+	}
+
+	if !session.ExpiresAt.After(time.Now()) {
+		return fmt.Errorf("session has expired")
+	}
+
+	if err := s.sessionRepo.Touch(ctx, sessionID); err != nil {
+		// Log error but don't reject the request
+		fmt.Printf("Warning: failed to update session %s: %v\n", sessionID, err)
+	}
+
+	return nil
+}
+
+// ListSessions returns the user's active sessions, flagging the current one
+func (s *Service) ListSessions(
+	ctx context.Context,
+	userID, currentSessionID string,
+) (*ListSessionsResponse, error) {
+	sessions, err := s.sessionRepo.ListActive(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSessionID
+	}
+
+	return &ListSessionsResponse{
+		Sessions: sessions,
+		Total:    len(sessions),
+	}, nil
+}
+
+// RevokeSession revokes a single session of the user
+func (s *Service) RevokeSession(ctx context.Context, userID, sessionID string) error {
+	return s.sessionRepo.Revoke(ctx, userID, sessionID)
+}
+
+// RevokeAllSessions revokes every session of the user, including the current one
+func (s *Service) RevokeAllSessions(ctx context.Context, userID string) (int, error) {
+	return s.sessionRepo.RevokeAll(ctx, userID)
+}
+
+// ============================================================
+// Personal Access Token Methods
+// ============================================================
+
+// CreateAccessToken creates a scoped personal access token and returns its plain value once
+func (s *Service) CreateAccessToken(
+	ctx context.Context,
+	userID string,
+	req CreateAccessTokenRequest,
+) (*CreateAccessTokenResponse, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if len(name) > 100 {
+		return nil, fmt.Errorf("name must be at most 100 characters long")
+	}
+
+	if len(req.Scopes) == 0 {
+		return nil, fmt.Errorf("at least one scope is required")
+	}
+
+	scopes := make([]string, 0, len(req.Scopes))
+	for _, scope := range req.Scopes {
+		if !validScopes[scope] {
+			return nil, fmt.Errorf("unknown scope: %s", scope)
+		}
+		if !hasScope(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	if req.ExpiresInDays < 0 {
+		return nil, fmt.Errorf("expires_in_days cannot be negative")
+	}
+
+	secret, err := generateOpaqueToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+	plain := accessTokenPrefix + secret
+
+	token := &PersonalAccessToken{
+		ID:          uuid.New().String(),
+		UserID:      userID,
+		Name:        name,
+		TokenHash:   hashToken(plain),
+		TokenPrefix: plain[:len(accessTokenPrefix)+8],
+		Scopes:      scopes,
+		CreatedAt:   time.Now(),
+	}
+
+	if req.ExpiresInDays > 0 {
+		expiresAt := token.CreatedAt.AddDate(0, 0, req.ExpiresInDays)
+		token.ExpiresAt = &expiresAt
+	}
+
+	if err := s.accessTokenRepo.Create(ctx, token); err != nil {
+		return nil, err
+	}
+
+	return &CreateAccessTokenResponse{
+		Token:       plain,
+		AccessToken: *token,
+	}, nil
+}
+
+// ListAccessTokens returns the user's personal access tokens that have not been revoked
+func (s *Service) ListAccessTokens(ctx context.Context, userID string) (*ListAccessTokensResponse, error) {
+	tokens, err := s.accessTokenRepo.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ListAccessTokensResponse{
+		Tokens: tokens,
+		Total:  len(tokens),
+	}, nil
+}
+
+// RevokeAccessToken revokes a personal access token
+func (s *Service) RevokeAccessToken(ctx context.Context, userID, tokenID string) error {
+	return s.accessTokenRepo.Revoke(ctx, userID, tokenID)
+}
+
+// IsPersonalAccessToken reports whether a bearer token looks like a personal access token
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, accessTokenPrefix)
+}
+
+// ValidatePersonalAccessToken returns the stored token if it is active
+func (s *Service) ValidatePersonalAccessToken(
+	ctx context.Context,
+	plain string,
+) (*PersonalAccessToken, error) {
+	token, err := s.accessTokenRepo.FindByHash(ctx, hashToken(plain))
+	if err != nil {
+		return nil, err
+	}
+
+	if token.RevokedAt != nil {
+		return nil, fmt.Errorf("access token has been revoked")
+	}
+
+	if token.ExpiresAt != nil && time.Now().After(*token.ExpiresAt) {
+		return nil, fmt.Errorf("access token has expired")
+	}
+
+	if err := s.accessTokenRepo.TouchLastUsed(ctx, token.ID); err != nil {
+		// Log error but don't reject the request
+		fmt.Printf("Warning: failed to update access token %s: %v\n", token.ID, err)
+	}
+
+	return token, nil
+}
+
+// generateOpaqueToken returns 32 random bytes, hex encoded
+func generateOpaqueToken() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+// hashToken returns the hex SHA-256 digest used to store and look up opaque tokens
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *Service) generateAccessToken(userID, sessionID string) (string, error) {
+	claims := jwt.MapClaims{
+		"user_id": userID,
+		"sid":     sessionID,
+		"exp":     time.Now().Add(s.cfg.AccessTokenExpiry).Unix(),
+		"iat":     time.Now().Unix(),
+	}
+
+	return s.cfg.AccessKeys.Sign(claims)
+}
+
+// generateChallengeToken signs the short-lived token that links the password step
+// of a login to the second-factor step
+func (s *Service) generateChallengeToken(userID, authMethod string) (string, error) {
+	claims := jwt.MapClaims{
+		"user_id":     userID,
+		"auth_method": authMethod,
+		"typ":         challengeTokenType,
+		"exp":         time.Now().Add(s.cfg.TwoFactorChallengeExpiry).Unix(),
+		"iat":         time.Now().Unix(),
+	}
+
+	return s.cfg.AccessKeys.Sign(claims)
+}
+
+// generateRefreshToken signs a new refresh token for the family and returns its record
+func (s *Service) generateRefreshToken(userID, familyID string) (*RefreshToken, string, error) {
+	now := time.Now()
+	record := &RefreshToken{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		FamilyID:  familyID,
+		ExpiresAt: now.Add(s.cfg.RefreshTokenExpiry),
+		CreatedAt: now,
+	}
+
+	claims := jwt.MapClaims{
+		"user_id": userID,
+		"jti":     record.ID,
+		"exp":     record.ExpiresAt.Unix(),
+		"iat":     now.Unix(),
+	}
+
+	signed, err := s.cfg.RefreshKeys.Sign(claims)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return record, signed, nil
+}
+
+// ValidateAccessToken returns the user and session IDs carried by a valid access token
+func (s *Service) ValidateAccessToken(tokenString string) (string, string, error) {
+	claims, err := s.cfg.AccessKeys.Parse(tokenString)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid token: %w", err)
+	}
+
+	// Challenge tokens share the signing key but must never grant access
+	if _, typed := claims["typ"]; typed {
+		return "", "", fmt.Errorf("invalid token type")
+	}
+	userID, ok := claims["user_id"].(string)
+	if !ok {
+		return "", "", fmt.Errorf("invalid token claims")
+	}
+	// Every access token belongs to a session, so revocation and expiry always apply
+	sessionID, ok := claims["sid"].(string)
+	if !ok || sessionID == "" {
+		return "", "", fmt.Errorf("invalid token claims")
+	}
+	return userID, sessionID, nil
+}
+
+// validateRefreshToken returns the token ID of a valid refresh token
+func (s *Service) validateRefreshToken(tokenString string) (string, error) {
+	claims, err := s.cfg.RefreshKeys.Parse(tokenString)
+	if err != nil {
+		return "", fmt.Errorf("invalid token: %w", err)
+	}
+
+	tokenID, ok := claims["jti"].(string)
+	if !ok || tokenID == "" {
+		return "", fmt.Errorf("invalid token claims")
+	}
+	return tokenID, nil
+}
+
+// validateChallengeToken returns the user ID and first-factor method of a valid
+// two-factor challenge token
+func (s *Service) validateChallengeToken(tokenString string) (userID, authMethod string, err error) {
+	claims, err := s.cfg.AccessKeys.Parse(tokenString)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid token: %w", err)
+	}
+
+	if typ, _ := claims["typ"].(string); typ != challengeTokenType {
+		return "", "", fmt.Errorf("invalid token type")
+	}
+	userID, ok := claims["user_id"].(string)
+	if !ok || userID == "" {
+		return "", "", fmt.Errorf("invalid token claims")
+	}
+	if authMethod, _ = claims["auth_method"].(string); authMethod != AuthMethodOIDC {
+		authMethod = AuthMethodPassword
+	}
+	return userID, authMethod, nil
+}
+
+// JWKS returns the public keys other services can use to verify access tokens
+func (s *Service) JWKS() jwtkeys.JWKSet {
+	return s.cfg.AccessKeys.JWKS()
+}
+
+// DeleteAccount permanently removes the user with all notes, versions, tags, chats
+// and vectors after confirming the password (and second factor, if enabled). Accounts
+// without a password confirm with a session from a recent single sign-on login instead.
+func (s *Service) DeleteAccount(
+	ctx context.Context,
+	userID, sessionID string,
+	req DeleteAccountRequest,
+) (*AccountDeletionReport, error) {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.confirmIdentity(ctx, user, sessionID, req.Password); err != nil {
+		return nil, err
+	}
+
+	enabled, err := s.twoFactorRepo.IsEnabled(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if enabled {
+		if err := s.verifySecondFactor(ctx, userID, req.Code, req.RecoveryCode); err != nil {
+			return nil, err
+		}
+	}
+
+	// Purge vectors first: if Postgres fails afterwards the account still exists and
+	// deletion can be retried, whereas orphaned vectors could never be found again
+	vectors, err := s.vectorStore.DeleteUserPoints(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete vectors: %w", err)
+	}
+
+	report, err := s.userRepo.Delete(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	report.Vectors = vectors
+
+	return report, nil
+}
+
+func (s *Service) GetUserByID(ctx context.Context, userID string) (*User, error) {
+	return s.userRepo.FindByID(ctx, userID)
+}
+
+// GenerateTokensForUser starts a new session signed in with authMethod and returns its token pair
+func (s *Service) GenerateTokensForUser(
+	ctx context.Context,
+	userID string,
+	authMethod string,
+	client ClientInfo,
+) (accessToken, refreshToken string, err error) {
+	sessionID := uuid.New().String()
+
+	accessToken, err = s.generateAccessToken(userID, sessionID)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate access token: %w", err)
+	}
+
+	record, refreshToken, err := s.generateRefreshToken(userID, sessionID)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+
+	session := &Session{
+		ID:         sessionID,
+		UserID:     userID,
+		UserAgent:  client.UserAgent,
+		IPAddress:  client.IPAddress,
+		AuthMethod: authMethod,
+		CreatedAt:  record.CreatedAt,
+		LastSeenAt: record.CreatedAt,
+		ExpiresAt:  record.ExpiresAt,
+	}
+
+	if err := s.sessionRepo.Create(ctx, session); err != nil {
+		return "", "", err
+	}
+
+	if err := s.tokenRepo.Create(ctx, record); err != nil {
+		return "", "", err
 	}
 
 	return accessToken, refreshToken, nil
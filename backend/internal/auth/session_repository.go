@@ -0,0 +1,201 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresSessionRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresSessionRepository(db *pgxpool.Pool) *PostgresSessionRepository {
+	return &PostgresSessionRepository{db: db}
+}
+
+func (r *PostgresSessionRepository) Create(ctx context.Context, session *Session) error {
+	query := `
+		INSERT INTO sessions (id, user_id, user_agent, ip_address, auth_method, created_at, last_seen_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		session.ID, session.UserID, session.UserAgent, session.IPAddress, session.AuthMethod,
+		session.CreatedAt, session.LastSeenAt, session.ExpiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+
+	return nil
+}
+
+func (r *PostgresSessionRepository) FindByID(ctx context.Context, userID, sessionID string) (*Session, error) {
+	session := &Session{}
+
+	query := `
+		SELECT id, user_id, user_agent, ip_address, auth_method, created_at, last_seen_at, expires_at, revoked_at
+		FROM sessions
+		WHERE id = $1 AND user_id = $2
+	`
+
+	err := r.db.QueryRow(ctx, query, sessionID, userID).Scan(
+		&session.ID, &session.UserID, &session.UserAgent, &session.IPAddress, &session.AuthMethod,
+		&session.CreatedAt, &session.LastSeenAt, &session.ExpiresAt, &session.RevokedAt,
+	)
+
+	if err == pgx.ErrNoRows {
+		return nil, fmt.Errorf("session not found")
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to find session: %w", err)
+	}
+
+	return session, nil
+}
+
+// ListActive returns the user's sessions that are neither revoked nor expired
+func (r *PostgresSessionRepository) ListActive(ctx context.Context, userID string) ([]Session, error) {
+	query := `
+		SELECT id, user_id, user_agent, ip_address, auth_method, created_at, last_seen_at, expires_at, revoked_at
+		FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY last_seen_at DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		var session Session
+		err := rows.Scan(
+			&session.ID, &session.UserID, &session.UserAgent, &session.IPAddress, &session.AuthMethod,
+			&session.CreatedAt, &session.LastSeenAt, &session.ExpiresAt, &session.RevokedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, nil
+}
+
+// Touch records activity on a session, writing at most once per minute
+func (r *PostgresSessionRepository) Touch(ctx context.Context, sessionID string) error {
+	query := `
+		UPDATE sessions
+		SET last_seen_at = NOW()
+		WHERE id = $1 AND last_seen_at < NOW() - INTERVAL '1 minute'
+	`
+
+	_, err := r.db.Exec(ctx, query, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to update session: %w", err)
+	}
+
+	return nil
+}
+
+// Extend moves the session expiry forward after a refresh token rotation
+func (r *PostgresSessionRepository) Extend(ctx context.Context, sessionID string, expiresAt time.Time) error {
+	query := `
+		UPDATE sessions
+		SET last_seen_at = NOW(), expires_at = $1
+		WHERE id = $2
+	`
+
+	_, err := r.db.Exec(ctx, query, expiresAt, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to extend session: %w", err)
+	}
+
+	return nil
+}
+
+// Revoke revokes a session together with all of its refresh tokens
+func (r *PostgresSessionRepository) Revoke(ctx context.Context, userID, sessionID string) error {
+	// Start transaction
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	now := time.Now()
+
+	result, err := tx.Exec(ctx, `
+		UPDATE sessions
+		SET revoked_at = COALESCE(revoked_at, $1)
+		WHERE id = $2 AND user_id = $3
+	`, now, sessionID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("session not found")
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE refresh_tokens
+		SET revoked_at = $1
+		WHERE family_id = $2 AND revoked_at IS NULL
+	`, now, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+
+	// Commit transaction
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeAll revokes every session of the user and returns how many were active
+func (r *PostgresSessionRepository) RevokeAll(ctx context.Context, userID string) (int, error) {
+	// Start transaction
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	now := time.Now()
+
+	result, err := tx.Exec(ctx, `
+		UPDATE sessions
+		SET revoked_at = $1
+		WHERE user_id = $2 AND revoked_at IS NULL
+	`, now, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE refresh_tokens
+		SET revoked_at = $1
+		WHERE user_id = $2 AND revoked_at IS NULL
+	`, now, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+
+	// Commit transaction
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return int(result.RowsAffected()), nil
+}
@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresThrottleRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresThrottleRepository(db *pgxpool.Pool) *PostgresThrottleRepository {
+	return &PostgresThrottleRepository{db: db}
+}
+
+// LockedUntil returns the latest lock expiry among the keys, or the zero time if none is locked
+func (r *PostgresThrottleRepository) LockedUntil(ctx context.Context, keys []string) (time.Time, error) {
+	query := `
+		SELECT COALESCE(MAX(locked_until), 'epoch'::timestamp)
+		FROM login_throttles
+		WHERE throttle_key = ANY($1) AND locked_until > $2
+	`
+
+	var lockedUntil time.Time
+	if err := r.db.QueryRow(ctx, query, keys, time.Now()).Scan(&lockedUntil); err != nil {
+		return time.Time{}, fmt.Errorf("failed to check lockout: %w", err)
+	}
+
+	if !lockedUntil.After(time.Now()) {
+		return time.Time{}, nil
+	}
+
+	return lockedUntil, nil
+}
+
+// Increment counts a failure and returns the failures in the current window and the
+// number of consecutive lockouts so far. Failures older than window start a new count;
+// lockouts are forgotten after a quiet period of lockoutMemory.
+func (r *PostgresThrottleRepository) Increment(
+	ctx context.Context,
+	key string,
+	window time.Duration,
+) (int, int, error) {
+	now := time.Now()
+
+	query := `
+		INSERT INTO login_throttles (throttle_key, failures, last_failure_at)
+		VALUES ($1, 1, $2)
+		ON CONFLICT (throttle_key) DO UPDATE
+		SET failures = CASE
+				WHEN login_throttles.last_failure_at < $3 THEN 1
+				ELSE login_throttles.failures + 1
+			END,
+			lockouts = CASE
+				WHEN login_throttles.last_failure_at < $4 THEN 0
+				ELSE login_throttles.lockouts
+			END,
+			last_failure_at = $2
+		RETURNING failures, lockouts
+	`
+
+	var failures, lockouts int
+	err := r.db.QueryRow(ctx, query, key, now, now.Add(-window), now.Add(-lockoutMemory)).Scan(&failures, &lockouts)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to record failed attempt: %w", err)
+	}
+
+	return failures, lockouts, nil
+}
+
+// Lock locks a key until the given time and starts a new failure count
+func (r *PostgresThrottleRepository) Lock(ctx context.Context, key string, until time.Time) error {
+	query := `
+		UPDATE login_throttles
+		SET locked_until = $1, lockouts = lockouts + 1, failures = 0
+		WHERE throttle_key = $2
+	`
+
+	if _, err := r.db.Exec(ctx, query, until, key); err != nil {
+		return fmt.Errorf("failed to lock: %w", err)
+	}
+
+	return nil
+}
+
+// Reset clears failures and lockouts of a key, e.g. after a successful login
+func (r *PostgresThrottleRepository) Reset(ctx context.Context, key string) error {
+	if _, err := r.db.Exec(ctx, `DELETE FROM login_throttles WHERE throttle_key = $1`, key); err != nil {
+		return fmt.Errorf("failed to reset throttle: %w", err)
+	}
+
+	return nil
+}
+
+// CreateEvent records a security event for a user
+func (r *PostgresThrottleRepository) CreateEvent(ctx context.Context, event *SecurityEvent) error {
+	query := `
+		INSERT INTO security_events (id, user_id, event_type, ip_address, user_agent, locked_until, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		event.ID, event.UserID, event.Type, event.IPAddress, event.UserAgent, event.LockedUntil, event.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create security event: %w", err)
+	}
+
+	return nil
+}
+
+// ListEvents returns the most recent security events of a user
+func (r *PostgresThrottleRepository) ListEvents(ctx context.Context, userID string, limit int) ([]SecurityEvent, error) {
+	query := `
+		SELECT id, user_id, event_type, ip_address, user_agent, locked_until, created_at
+		FROM security_events
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`
+
+	rows, err := r.db.Query(ctx, query, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list security events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []SecurityEvent{}
+	for rows.Next() {
+		var event SecurityEvent
+		err := rows.Scan(
+			&event.ID,
+			&event.UserID,
+			&event.Type,
+			&event.IPAddress,
+			&event.UserAgent,
+			&event.LockedUntil,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan security event: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
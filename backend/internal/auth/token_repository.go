@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresRefreshTokenRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresRefreshTokenRepository(db *pgxpool.Pool) *PostgresRefreshTokenRepository {
+	return &PostgresRefreshTokenRepository{db: db}
+}
+
+func (r *PostgresRefreshTokenRepository) Create(ctx context.Context, token *RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (id, user_id, family_id, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		token.ID, token.UserID, token.FamilyID, token.ExpiresAt, token.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+
+	return nil
+}
+
+func (r *PostgresRefreshTokenRepository) FindByID(ctx context.Context, id string) (*RefreshToken, error) {
+	token := &RefreshToken{}
+
+	query := `
+		SELECT id, user_id, family_id, replaced_by, expires_at, revoked_at, created_at
+		FROM refresh_tokens
+		WHERE id = $1
+	`
+
+	err := r.db.QueryRow(ctx, query, id).Scan(
+		&token.ID, &token.UserID, &token.FamilyID, &token.ReplacedBy,
+		&token.ExpiresAt, &token.RevokedAt, &token.CreatedAt,
+	)
+
+	if err == pgx.ErrNoRows {
+		return nil, fmt.Errorf("refresh token not found")
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to find refresh token: %w", err)
+	}
+
+	return token, nil
+}
+
+// Rotate marks oldID as replaced by next and stores next in the same transaction.
+// Returns false without storing next if oldID was already rotated or revoked.
+func (r *PostgresRefreshTokenRepository) Rotate(
+	ctx context.Context,
+	oldID string,
+	next *RefreshToken,
+) (bool, error) {
+	// Start transaction
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Only one caller can win the rotation of a given token
+	result, err := tx.Exec(ctx, `
+		UPDATE refresh_tokens
+		SET replaced_by = $1
+		WHERE id = $2 AND replaced_by IS NULL AND revoked_at IS NULL
+	`, next.ID, oldID)
+	if err != nil {
+		return false, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO refresh_tokens (id, user_id, family_id, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, next.ID, next.UserID, next.FamilyID, next.ExpiresAt, next.CreatedAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to create refresh token: %w", err)
+	}
+
+	// Commit transaction
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,186 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresTwoFactorRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresTwoFactorRepository(db *pgxpool.Pool) *PostgresTwoFactorRepository {
+	return &PostgresTwoFactorRepository{db: db}
+}
+
+// SavePending stores a new unconfirmed secret, replacing any earlier pending enrollment.
+// An already enabled enrollment is left untouched.
+func (r *PostgresTwoFactorRepository) SavePending(ctx context.Context, userID, secret string) error {
+	query := `
+		INSERT INTO user_totp (user_id, secret, created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, last_used_step = 0, created_at = EXCLUDED.created_at
+		WHERE user_totp.enabled_at IS NULL
+	`
+
+	result, err := r.db.Exec(ctx, query, userID, secret, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to save totp secret: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("two-factor authentication is already enabled")
+	}
+
+	return nil
+}
+
+func (r *PostgresTwoFactorRepository) Find(ctx context.Context, userID string) (*TOTPSecret, error) {
+	query := `
+		SELECT user_id, secret, enabled_at, last_used_step, created_at
+		FROM user_totp
+		WHERE user_id = $1
+	`
+
+	var secret TOTPSecret
+	err := r.db.QueryRow(ctx, query, userID).Scan(
+		&secret.UserID,
+		&secret.Secret,
+		&secret.EnabledAt,
+		&secret.LastUsedStep,
+		&secret.CreatedAt,
+	)
+
+	if err == pgx.ErrNoRows {
+		return nil, fmt.Errorf("two-factor authentication is not set up")
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to find totp secret: %w", err)
+	}
+
+	return &secret, nil
+}
+
+func (r *PostgresTwoFactorRepository) IsEnabled(ctx context.Context, userID string) (bool, error) {
+	var enabled bool
+	err := r.db.QueryRow(ctx, `
+		SELECT EXISTS(SELECT 1 FROM user_totp WHERE user_id = $1 AND enabled_at IS NOT NULL)
+	`, userID).Scan(&enabled)
+	if err != nil {
+		return false, fmt.Errorf("failed to check two-factor status: %w", err)
+	}
+
+	return enabled, nil
+}
+
+// Enable confirms the pending enrollment and replaces the user's recovery codes
+func (r *PostgresTwoFactorRepository) Enable(
+	ctx context.Context,
+	userID string,
+	step int64,
+	recoveryCodeHashes []string,
+) error {
+	// Start transaction
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	now := time.Now()
+
+	result, err := tx.Exec(ctx, `
+		UPDATE user_totp
+		SET enabled_at = $1, last_used_step = $2
+		WHERE user_id = $3 AND enabled_at IS NULL
+	`, now, step, userID)
+	if err != nil {
+		return fmt.Errorf("failed to enable totp: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("no pending two-factor enrollment")
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	for _, codeHash := range recoveryCodeHashes {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO recovery_codes (id, user_id, code_hash, created_at)
+			VALUES ($1, $2, $3, $4)
+		`, uuid.New().String(), userID, codeHash, now)
+		if err != nil {
+			return fmt.Errorf("failed to create recovery code: %w", err)
+		}
+	}
+
+	// Commit transaction
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// UseStep records a time step as used. It returns false if that step or a later one
+// was already accepted, so a code cannot be replayed.
+func (r *PostgresTwoFactorRepository) UseStep(ctx context.Context, userID string, step int64) (bool, error) {
+	result, err := r.db.Exec(ctx, `
+		UPDATE user_totp
+		SET last_used_step = $1
+		WHERE user_id = $2 AND last_used_step < $1
+	`, step, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to record totp step: %w", err)
+	}
+
+	return result.RowsAffected() == 1, nil
+}
+
+// UseRecoveryCode marks an unused recovery code as used. It returns false if no such code exists.
+func (r *PostgresTwoFactorRepository) UseRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error) {
+	result, err := r.db.Exec(ctx, `
+		UPDATE recovery_codes
+		SET used_at = $1
+		WHERE user_id = $2 AND code_hash = $3 AND used_at IS NULL
+	`, time.Now(), userID, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+
+	return result.RowsAffected() == 1, nil
+}
+
+// Delete removes the enrollment and all recovery codes of the user
+func (r *PostgresTwoFactorRepository) Delete(ctx context.Context, userID string) error {
+	// Start transaction
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM user_totp WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete totp secret: %w", err)
+	}
+
+	// Commit transaction
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
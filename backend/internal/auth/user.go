@@ -3,26 +3,394 @@ package auth
 import "time"
 
 type User struct {
-	ID           string    `json:"id"`
-	Email        string    `json:"email"`
-	PasswordHash string    `json:"-"`
-	Name         *string   `json:"name,omitempty"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID              string     `json:"id"`
+	Email           string     `json:"email"`
+	PasswordHash    string     `json:"-"`
+	HasPassword     bool       `json:"has_password"` // False for accounts created by single sign-on until a password is set
+	Name            *string    `json:"name,omitempty"`
+	AvatarURL       *string    `json:"avatar_url,omitempty"`
+	Timezone        *string    `json:"timezone,omitempty"`
+	Locale          *string    `json:"locale,omitempty"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	Role            string     `json:"role"`
+	DisabledAt      *time.Time `json:"disabled_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
 }
 
+// How a session was signed in
+const (
+	AuthMethodPassword = "password"
+	AuthMethodOIDC     = "oidc"
+)
+
+// User roles
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 type RegisterRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email      string     `json:"email"`
+	Password   string     `json:"password"`
+	InviteCode string     `json:"invite_code,omitempty"` // Required when registration is invite-only
+	Client     ClientInfo `json:"-"`                     // Set from the HTTP request, not from request body
 }
 
 type LoginRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email    string     `json:"email"`
+	Password string     `json:"password"`
+	Client   ClientInfo `json:"-"` // Set from the HTTP request, not from request body
+}
+
+// ClientInfo describes the device a session was started from
+type ClientInfo struct {
+	UserAgent string
+	IPAddress string
 }
 
+// AuthResponse carries either a token pair or, when the account has two-factor
+// authentication enabled, a challenge token to be exchanged via /auth/2fa/verify
 type AuthResponse struct {
-	AccessToken  string `json:"access_token"`
+	AccessToken       string `json:"access_token,omitempty"`
+	RefreshToken      string `json:"refresh_token,omitempty"`
+	TwoFactorRequired bool   `json:"two_factor_required,omitempty"`
+	ChallengeToken    string `json:"challenge_token,omitempty"`
+	User              User   `json:"user"`
+}
+
+// RefreshToken is the server-side record of an issued refresh token
+type RefreshToken struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	FamilyID   string     `json:"family_id"` // Shared by all tokens rotated from the same login
+	ReplacedBy *string    `json:"replaced_by,omitempty"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type RefreshRequest struct {
 	RefreshToken string `json:"refresh_token"`
-	User         User   `json:"user"`
+}
+
+// Session represents a logged-in device; its ID is the refresh token family ID
+type Session struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	AuthMethod string     `json:"auth_method"` // AuthMethodPassword or AuthMethodOIDC
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	Current    bool       `json:"current"` // True for the session making the request
+}
+
+type ListSessionsResponse struct {
+	Sessions []Session `json:"sessions"`
+	Total    int       `json:"total"`
+}
+
+// PersonalAccessToken is a long-lived, scoped API credential; only its hash is stored
+type PersonalAccessToken struct {
+	ID          string     `json:"id"`
+	UserID      string     `json:"user_id"`
+	Name        string     `json:"name"`
+	TokenHash   string     `json:"-"`
+	TokenPrefix string     `json:"token_prefix"`
+	Scopes      []string   `json:"scopes"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+type CreateAccessTokenRequest struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expires_in_days,omitempty"` // 0 means the token never expires
+}
+
+// CreateAccessTokenResponse carries the plain token, which is only ever shown once
+type CreateAccessTokenResponse struct {
+	Token       string              `json:"token"`
+	AccessToken PersonalAccessToken `json:"access_token"`
+}
+
+type ListAccessTokensResponse struct {
+	Tokens []PersonalAccessToken `json:"tokens"`
+	Total  int                   `json:"total"`
+}
+
+// PasswordResetToken is a single-use token emailed to reset a forgotten password
+type PasswordResetToken struct {
+	ID        string     `json:"id"`
+	UserID    string     `json:"user_id"`
+	TokenHash string     `json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string     `json:"current_password"`
+	NewPassword     string     `json:"new_password"`
+	Client          ClientInfo `json:"-"` // Set from the HTTP request, not from request body
+}
+
+// EmailVerificationToken is a single-use token emailed to confirm an address
+type EmailVerificationToken struct {
+	ID        string     `json:"id"`
+	UserID    string     `json:"user_id"`
+	TokenHash string     `json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+// TOTPSecret is the TOTP enrollment of a user
+type TOTPSecret struct {
+	UserID       string     `json:"user_id"`
+	Secret       string     `json:"-"`
+	EnabledAt    *time.Time `json:"enabled_at,omitempty"` // nil while enrollment awaits confirmation
+	LastUsedStep int64      `json:"-"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// EnrollTOTPResponse carries the secret for the authenticator app, shown only during enrollment
+type EnrollTOTPResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type ConfirmTOTPRequest struct {
+	Code string `json:"code"`
+}
+
+// RecoveryCodesResponse carries the plain recovery codes, which are only ever shown once
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// VerifyTwoFactorRequest completes a two-step login with either a TOTP code or a recovery code
+type VerifyTwoFactorRequest struct {
+	ChallengeToken string     `json:"challenge_token"`
+	Code           string     `json:"code,omitempty"`
+	RecoveryCode   string     `json:"recovery_code,omitempty"`
+	Client         ClientInfo `json:"-"` // Set from the HTTP request, not from request body
+}
+
+// DisableTOTPRequest re-authenticates the user with their password and a second factor.
+// Accounts without a password sign in with their identity provider again instead.
+type DisableTOTPRequest struct {
+	Password     string `json:"password,omitempty"`
+	Code         string `json:"code,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+// SecurityEvent records something security relevant on an account, such as a lockout
+type SecurityEvent struct {
+	ID          string     `json:"id"`
+	UserID      string     `json:"user_id"`
+	Type        string     `json:"type"` // "login_locked" or "two_factor_locked"
+	IPAddress   string     `json:"ip_address"`
+	UserAgent   string     `json:"user_agent"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+type ListSecurityEventsResponse struct {
+	Events []SecurityEvent `json:"events"`
+	Total  int             `json:"total"`
+}
+
+// LockedError is returned while an account, IP or 2FA challenge is locked out
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return "too many attempts, try again later"
+}
+
+// DeleteAccountRequest confirms account deletion with the password, plus a
+// second factor when 2FA is enabled. Accounts without a password confirm by
+// signing in with their identity provider again instead.
+type DeleteAccountRequest struct {
+	Password     string `json:"password,omitempty"`
+	Code         string `json:"code,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+// AccountDeletionReport lists how much data was removed with an account
+type AccountDeletionReport struct {
+	Notes             int    `json:"notes"`
+	NoteVersions      int    `json:"note_versions"`
+	NoteLinks         int    `json:"note_links"`
+	Tags              int    `json:"tags"`
+	ChatConversations int    `json:"chat_conversations"`
+	ChatMessages      int    `json:"chat_messages"`
+	Sessions          int    `json:"sessions"`
+	AccessTokens      int    `json:"access_tokens"`
+	Vectors           uint64 `json:"vectors"`
+}
+
+// ExternalIdentity is a user identity asserted by an OpenID Connect provider
+type ExternalIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// UserIdentity links a user to an external identity
+type UserIdentity struct {
+	ID          string    `json:"id"`
+	UserID      string    `json:"user_id"`
+	Provider    string    `json:"provider"`
+	Subject     string    `json:"subject"`
+	Email       string    `json:"email"`
+	CreatedAt   time.Time `json:"created_at"`
+	LastLoginAt time.Time `json:"last_login_at"`
+}
+
+// OIDCLoginState carries state, nonce and PKCE verifier from login start to callback
+type OIDCLoginState struct {
+	State        string
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+	ExpiresAt    time.Time
+	CreatedAt    time.Time
+}
+
+type OIDCProviderInfo struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+}
+
+type ListOIDCProvidersResponse struct {
+	Providers []OIDCProviderInfo `json:"providers"`
+}
+
+type StartOIDCLoginResponse struct {
+	AuthorizationURL string `json:"authorization_url"`
+	State            string `json:"state"`
+}
+
+// OIDCCallbackRequest carries the code and state the provider sent to the web app's redirect URL
+type OIDCCallbackRequest struct {
+	Code   string     `json:"code"`
+	State  string     `json:"state"`
+	Client ClientInfo `json:"-"` // Set from the HTTP request, not from request body
+}
+
+// AdminUser is a user as listed to administrators, with usage counts
+type AdminUser struct {
+	User
+	NoteCount   int        `json:"note_count"`
+	TagCount    int        `json:"tag_count"`
+	VectorCount uint64     `json:"vector_count"`
+	LastSeenAt  *time.Time `json:"last_seen_at,omitempty"`
+}
+
+type ListUsersRequest struct {
+	Page    int    `json:"page"`
+	PerPage int    `json:"per_page"`
+	Search  string `json:"search,omitempty"` // Matches part of the email
+}
+
+type ListUsersResponse struct {
+	Users      []AdminUser `json:"users"`
+	Total      int         `json:"total"`
+	Page       int         `json:"page"`
+	PerPage    int         `json:"per_page"`
+	TotalPages int         `json:"total_pages"`
+}
+
+type SetUserRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// InviteCode allows registration while registration is invite-only
+type InviteCode struct {
+	ID        string     `json:"id"`
+	CodeHash  string     `json:"-"`
+	Email     *string    `json:"email,omitempty"`
+	CreatedBy string     `json:"created_by"`
+	UsedBy    *string    `json:"used_by,omitempty"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type CreateInviteRequest struct {
+	Email         string `json:"email,omitempty"`           // Restrict the invite to this address
+	ExpiresInDays int    `json:"expires_in_days,omitempty"` // 0 means the invite never expires
+}
+
+// CreateInviteResponse carries the plain invite code, which is only ever shown once
+type CreateInviteResponse struct {
+	Code   string     `json:"code"`
+	Invite InviteCode `json:"invite"`
+}
+
+type ListInvitesResponse struct {
+	Invites []InviteCode `json:"invites"`
+	Total   int          `json:"total"`
+}
+
+// Preferences is the user's preferences document. Nil fields fall back to the
+// server configuration.
+type Preferences struct {
+	PageSize          *int    `json:"page_size"`
+	SearchLanguage    *string `json:"search_language"` // Postgres text search configuration, e.g. "english"
+	ChatModel         *string `json:"chat_model"`
+	DefaultVisibility *string `json:"default_visibility"` // VisibilityPrivate or VisibilityPublic
+}
+
+// Default note visibility
+const (
+	VisibilityPrivate = "private"
+	VisibilityPublic  = "public"
+)
+
+// ProfileResponse is returned by GET and PATCH /api/me
+type ProfileResponse struct {
+	User        User        `json:"user"`
+	Preferences Preferences `json:"preferences"`
+}
+
+// UpdateProfileRequest changes only the fields that are present; an empty
+// string clears a field
+type UpdateProfileRequest struct {
+	Name        *string                   `json:"name,omitempty"`
+	AvatarURL   *string                   `json:"avatar_url,omitempty"`
+	Timezone    *string                   `json:"timezone,omitempty"`
+	Locale      *string                   `json:"locale,omitempty"`
+	Preferences *UpdatePreferencesRequest `json:"preferences,omitempty"`
+}
+
+// UpdatePreferencesRequest changes only the preferences that are present; a zero
+// value ("" or 0) resets a preference to the server default
+type UpdatePreferencesRequest struct {
+	PageSize          *int    `json:"page_size,omitempty"`
+	SearchLanguage    *string `json:"search_language,omitempty"`
+	ChatModel         *string `json:"chat_model,omitempty"`
+	DefaultVisibility *string `json:"default_visibility,omitempty"`
 }
@@ -18,14 +18,28 @@ type OpenAIClient struct {
 	model   string
 }
 
-func NewOpenAIClient(apiKey string) *OpenAIClient {
+// DefaultModel is used when no chat model is configured
+const DefaultModel = "gpt-5-nano"
+
+func NewOpenAIClient(apiKey, model string) *OpenAIClient {
+	if model == "" {
+		model = DefaultModel // GPT-5 nano model
+	}
+
 	return &OpenAIClient{
 		apiKey:  apiKey,
 		baseURL: "https://api.openai.com/v1",
-		model:   "gpt-5-nano", // GPT-5 nano model
+		model:   model,
 	}
 }
 
+// WithModel returns a copy of the client that sends requests to another model
+func (c *OpenAIClient) WithModel(model string) *OpenAIClient {
+	clone := *c
+	clone.model = model
+	return &clone
+}
+
 // ChatCompletion sends a chat completion request (non-streaming)
 func (c *OpenAIClient) ChatCompletion(ctx context.Context, messages []OpenAIMessage) (*OpenAIResponse, error) {
 	reqBody := OpenAIRequest{
@@ -30,23 +30,51 @@ Title     string
 ContentMd string
 }
 
+// PreferenceProvider returns the chat model a user prefers ("" for the default)
+type PreferenceProvider interface {
+ChatModel(ctx context.Context, userID string) (string, error)
+}
+
 // Service handles chat business logic for single-note conversations
 type Service struct {
 chatRepo     ChatRepository
 noteRepo     NoteRepository
 openaiClient *OpenAIClient
+preferences  PreferenceProvider
 }
 
 func NewService(
 chatRepo ChatRepository,
 noteRepo NoteRepository,
 openaiClient *OpenAIClient,
+preferences PreferenceProvider,
 ) *Service {
 return &Service{
 chatRepo:     chatRepo,
 noteRepo:     noteRepo,
 openaiClient: openaiClient,
+preferences:  preferences,
+}
 }
+
+// clientFor returns the OpenAI client using the user's preferred model, falling
+// back to the configured model when there is no preference
+func (s *Service) clientFor(ctx context.Context, userID string) *OpenAIClient {
+if s.preferences == nil {
+return s.openaiClient
+}
+
+model, err := s.preferences.ChatModel(ctx, userID)
+if err != nil {
+fmt.Printf("Warning: failed to load chat model preference for user %s: %v\n", userID, err)
+return s.openaiClient
+}
+
+if model == "" {
+return s.openaiClient
+}
+
+return s.openaiClient.WithModel(model)
 }
 
 // CreateConversation creates a new conversation for a specific note
@@ -152,8 +180,8 @@ messages := []OpenAIMessage{
 {Role: "user", Content: userPrompt},
 }
 
-// 5. Call GPT-5 nano
-response, err := s.openaiClient.ChatCompletion(ctx, messages)
+// 5. Call the preferred model (GPT-5 nano by default)
+response, err := s.clientFor(ctx, userID).ChatCompletion(ctx, messages)
 if err != nil {
 return "", fmt.Errorf("failed to get AI response: %w", err)
 }
@@ -218,7 +246,7 @@ messages := []OpenAIMessage{
 
 // 5. Stream response
 var fullResponse strings.Builder
-err = s.openaiClient.ChatCompletionStream(ctx, messages, func(chunk string) error {
+err = s.clientFor(ctx, userID).ChatCompletionStream(ctx, messages, func(chunk string) error {
 fullResponse.WriteString(chunk)
 return callback(chunk)
 })
@@ -0,0 +1,170 @@
+package notes
+
+import (
+	"strings"
+	"unicode"
+)
+
+// maxChunkLength is the largest chunk in characters, well within the input limit of the embedding models
+const maxChunkLength = 1500
+
+// NoteChunk is a passage of a note that is embedded as its own vector
+type NoteChunk struct {
+	Index int
+	Start int // Character offset in the note content
+	End   int // Exclusive
+	Text  string
+}
+
+// chunkBlock is a run of lines of the note: a heading, a paragraph or a code block
+type chunkBlock struct {
+	start   int
+	end     int
+	heading bool
+}
+
+// chunkNote splits markdown content into chunks of at most maxChunkLength characters.
+// Every heading starts a new chunk, paragraphs are packed together until the chunk is
+// full, and only paragraphs longer than a chunk are cut, at a sentence end or space if possible.
+// Content without text still gets one empty chunk so that the title is indexed.
+func chunkNote(content string) []NoteChunk {
+	runes := []rune(content)
+	chunks := []NoteChunk{}
+
+	start, end := -1, -1
+	flush := func() {
+		if start >= 0 {
+			chunks = append(chunks, NoteChunk{
+				Index: len(chunks),
+				Start: start,
+				End:   end,
+				Text:  string(runes[start:end]),
+			})
+		}
+		start, end = -1, -1
+	}
+
+	for _, block := range chunkBlocks(runes) {
+		fitsAlone := block.end-block.start <= maxChunkLength
+		if block.heading || (start >= 0 && block.end-start > maxChunkLength && fitsAlone) {
+			flush()
+		}
+
+		// Cut blocks that do not fit into a chunk of their own; the first piece
+		// stays with what the chunk already holds, such as the section heading
+		for {
+			from := block.start
+			if start >= 0 {
+				from = start
+			}
+			if block.end-from <= maxChunkLength {
+				break
+			}
+
+			cut := chunkCut(runes, from, from+maxChunkLength)
+			if cut <= block.start {
+				flush() // A heading too long to share a chunk
+				continue
+			}
+			if start < 0 {
+				start = block.start
+			}
+			end = cut
+			flush()
+			block.start = skipSpace(runes, cut, block.end)
+		}
+
+		if block.start < block.end {
+			if start < 0 {
+				start = block.start
+			}
+			end = block.end
+		}
+	}
+	flush()
+
+	if len(chunks) == 0 {
+		chunks = append(chunks, NoteChunk{Index: 0, Start: 0, End: 0, Text: ""})
+	}
+
+	return chunks
+}
+
+// chunkBlocks splits content at blank lines and around headings. Blank lines inside
+// fenced code blocks do not end the block, and "#" lines inside them are not headings.
+func chunkBlocks(runes []rune) []chunkBlock {
+	blocks := []chunkBlock{}
+	current := chunkBlock{start: -1}
+	inFence := false
+
+	closeBlock := func() {
+		if current.start >= 0 {
+			blocks = append(blocks, current)
+		}
+		current = chunkBlock{start: -1}
+	}
+
+	lineStart := 0
+	for lineStart < len(runes) {
+		lineEnd := lineStart
+		for lineEnd < len(runes) && runes[lineEnd] != '\n' {
+			lineEnd++
+		}
+
+		line := strings.TrimSpace(string(runes[lineStart:lineEnd]))
+		switch {
+		case strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~"):
+			inFence = !inFence
+			fallthrough
+		case line != "" && (inFence || !isHeading(line)):
+			if current.start < 0 {
+				current.start = lineStart
+			}
+			current.end = lineEnd
+		case line != "":
+			closeBlock()
+			blocks = append(blocks, chunkBlock{start: lineStart, end: lineEnd, heading: true})
+		case !inFence:
+			closeBlock()
+		}
+
+		lineStart = lineEnd + 1
+	}
+	closeBlock()
+
+	return blocks
+}
+
+// isHeading reports whether a trimmed line is an ATX heading such as "## Setup"
+func isHeading(line string) bool {
+	level := 0
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	return level >= 1 && level <= 6 && (level == len(line) || line[level] == ' ' || line[level] == '\t')
+}
+
+// chunkCut returns where to cut a block that is too long: after the last sentence end or
+// space before limit, or at limit when there is none in the second half of the chunk
+func chunkCut(runes []rune, start, limit int) int {
+	space := -1
+	for i := limit; i > start+maxChunkLength/2; i-- {
+		if i > 0 && strings.ContainsRune(".!?", runes[i-1]) && unicode.IsSpace(runes[i]) {
+			return i
+		}
+		if space < 0 && unicode.IsSpace(runes[i]) {
+			space = i
+		}
+	}
+	if space >= 0 {
+		return space
+	}
+	return limit
+}
+
+func skipSpace(runes []rune, from, to int) int {
+	for from < to && unicode.IsSpace(runes[from]) {
+		from++
+	}
+	return from
+}
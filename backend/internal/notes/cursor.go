@@ -0,0 +1,60 @@
+package notes
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// NoteCursor is the position of the last note of a page in a sorted note list
+type NoteCursor struct {
+	Sort      string    `json:"s"`
+	Desc      bool      `json:"d,omitempty"`
+	CreatedAt time.Time `json:"c,omitempty"`
+	UpdatedAt time.Time `json:"u,omitempty"`
+	Title     string    `json:"t,omitempty"`
+	Rank      float32   `json:"r,omitempty"`
+	ID        string    `json:"i"`
+}
+
+// cursorAfter returns the cursor that continues a list after the given note
+func cursorAfter(note Note, sort string, desc bool) *NoteCursor {
+	cursor := &NoteCursor{Sort: sort, Desc: desc, ID: note.ID}
+
+	switch sort {
+	case NoteSortCreatedAt:
+		cursor.CreatedAt = note.CreatedAt
+	case NoteSortUpdatedAt:
+		cursor.UpdatedAt = note.UpdatedAt
+	case NoteSortTitle:
+		cursor.Title = note.Title
+	case NoteSortRelevance:
+		if note.Rank != nil {
+			cursor.Rank = *note.Rank
+		}
+	}
+
+	return cursor
+}
+
+// EncodeCursor turns a cursor into the opaque string handed to clients
+func EncodeCursor(cursor *NoteCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a cursor produced by EncodeCursor
+func DecodeCursor(value string) (*NoteCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	cursor := &NoteCursor{}
+	if err := json.Unmarshal(data, cursor); err != nil || cursor.ID == "" {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	return cursor, nil
+}
@@ -1,8 +1,10 @@
 package notes
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/labstack/echo/v4"
 )
@@ -48,6 +50,7 @@ func (h *Handler) GetNote(c echo.Context) error {
 		})
 	}
 
+	c.Response().Header().Set("ETag", noteETag(note.Version))
 	return c.JSON(http.StatusOK, note)
 }
 
@@ -62,16 +65,57 @@ func (h *Handler) UpdateNote(c echo.Context) error {
 		})
 	}
 
-	note, err := h.service.UpdateNote(c.Request().Context(), userID, noteID, req)
+	// Without If-Match the update is unconditional
+	baseVersion, err := parseIfMatch(c.Request().Header.Get("If-Match"))
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{
 			"error": err.Error(),
 		})
 	}
 
+	note, err := h.service.UpdateNote(c.Request().Context(), userID, noteID, req, baseVersion)
+	if conflict, ok := err.(*ConflictError); ok {
+		c.Response().Header().Set("ETag", noteETag(conflict.CurrentVersion))
+		return c.JSON(http.StatusConflict, map[string]interface{}{
+			"error":           conflict.Error(),
+			"base_version":    conflict.BaseVersion,
+			"current_version": conflict.CurrentVersion,
+			"note":            conflict.Current,
+			"diff":            conflict.Diff,
+		})
+	}
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	c.Response().Header().Set("ETag", noteETag(note.Version))
 	return c.JSON(http.StatusOK, note)
 }
 
+// noteETag formats a note version number as an ETag
+func noteETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// parseIfMatch returns the version in an If-Match header, or 0 when the header is empty or "*"
+func parseIfMatch(header string) (int, error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+
+	// Weak tags are accepted too: a note's version number identifies its content
+	value := strings.Trim(strings.TrimPrefix(header, "W/"), `"`)
+	version, err := strconv.Atoi(value)
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("invalid If-Match header")
+	}
+
+	return version, nil
+}
+
 func (h *Handler) DeleteNote(c echo.Context) error {
 	userID := c.Get("user_id").(string)
 	noteID := c.Param("id")
@@ -89,19 +133,118 @@ func (h *Handler) ListNotes(c echo.Context) error {
 	userID := c.Get("user_id").(string)
 
 	// Parse query parameters
+	req, err := parseListNotesQuery(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	response, err := h.service.ListNotes(c.Request().Context(), userID, req)
+	if err != nil {
+		return listNotesError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// QueryNotes lists notes with the filters given as a JSON body
+func (h *Handler) QueryNotes(c echo.Context) error {
+	userID := c.Get("user_id").(string)
+
+	var req ListNotesRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid request body",
+		})
+	}
+
+	response, err := h.service.ListNotes(c.Request().Context(), userID, req)
+	if err != nil {
+		return listNotesError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// listNotesError reports a failed note listing. Invalid requests are 400s, and query
+// errors include where the query went wrong; anything else is a server error.
+func listNotesError(c echo.Context, err error) error {
+	if queryErr, ok := err.(*QueryError); ok {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error":    queryErr.Error(),
+			"position": queryErr.Position,
+		})
+	}
+
+	if _, ok := err.(*ListRequestError); ok {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusInternalServerError, map[string]string{
+		"error": err.Error(),
+	})
+}
+
+// parseListNotesQuery reads a ListNotesRequest from the query string
+func parseListNotesQuery(c echo.Context) (ListNotesRequest, error) {
 	page, _ := strconv.Atoi(c.QueryParam("page"))
 	perPage, _ := strconv.Atoi(c.QueryParam("per_page"))
-	search := c.QueryParam("search")
-	tags := c.QueryParams()["tags"]
+	includeDescendants, _ := strconv.ParseBool(c.QueryParam("include_descendants"))
+	untagged, _ := strconv.ParseBool(c.QueryParam("untagged"))
 
 	req := ListNotesRequest{
-		Page:    page,
-		PerPage: perPage,
-		Search:  search,
-		Tags:    tags,
+		Page:               page,
+		PerPage:            perPage,
+		Search:             c.QueryParam("search"),
+		Query:              c.QueryParam("q"),
+		Tags:               c.QueryParams()["tags"],
+		AllTags:            c.QueryParams()["all_tags"],
+		ExcludeTags:        c.QueryParams()["exclude_tags"],
+		Untagged:           untagged,
+		CreatedAfter:       c.QueryParam("created_after"),
+		CreatedBefore:      c.QueryParam("created_before"),
+		UpdatedAfter:       c.QueryParam("updated_after"),
+		UpdatedBefore:      c.QueryParam("updated_before"),
+		SourceDomain:       c.QueryParam("source_domain"),
+		IncludeDescendants: includeDescendants,
+		Sort:               c.QueryParam("sort"),
+		Order:              c.QueryParam("order"),
+		Cursor:             c.QueryParam("cursor"),
 	}
 
-	response, err := h.service.ListNotes(c.Request().Context(), userID, req)
+	var err error
+	if req.HasSource, err = optionalBool(c, "has_source"); err != nil {
+		return req, err
+	}
+	if req.IsPublic, err = optionalBool(c, "is_public"); err != nil {
+		return req, err
+	}
+
+	return req, nil
+}
+
+// optionalBool parses a query parameter that may be absent, true or false
+func optionalBool(c echo.Context, name string) (*bool, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: must be true or false", name)
+	}
+
+	return &parsed, nil
+}
+
+func (h *Handler) ListTags(c echo.Context) error {
+	userID := c.Get("user_id").(string)
+
+	response, err := h.service.ListAllTags(c.Request().Context(), userID)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": err.Error(),
@@ -111,35 +254,145 @@ func (h *Handler) ListNotes(c echo.Context) error {
 	return c.JSON(http.StatusOK, response)
 }
 
-func (h *Handler) ListTags(c echo.Context) error {
+// GetTagAnalytics returns tag co-occurrence, usage over time, orphaned tags and merge suggestions
+func (h *Handler) GetTagAnalytics(c echo.Context) error {
 	userID := c.Get("user_id").(string)
+	weeks, _ := strconv.Atoi(c.QueryParam("weeks"))
 
-	tags, err := h.service.ListAllTags(c.Request().Context(), userID)
+	response, err := h.service.GetTagAnalytics(c.Request().Context(), userID, weeks)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": err.Error(),
 		})
 	}
 
-	return c.JSON(http.StatusOK, map[string]interface{}{
-		"tags": tags,
-	})
+	return c.JSON(http.StatusOK, response)
+}
+
+// UpdateTag renames a tag or changes its colour, icon, description or pinned flag
+func (h *Handler) UpdateTag(c echo.Context) error {
+	userID := c.Get("user_id").(string)
+	tagID := c.Param("id")
+
+	var req UpdateTagRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid request body",
+		})
+	}
+
+	tag, err := h.service.UpdateTag(c.Request().Context(), userID, tagID, req)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, tag)
+}
+
+// MergeTags folds several tags into one
+func (h *Handler) MergeTags(c echo.Context) error {
+	userID := c.Get("user_id").(string)
+
+	var req MergeTagsRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid request body",
+		})
+	}
+
+	response, err := h.service.MergeTags(c.Request().Context(), userID, req)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, response)
 }
 
 func (h *Handler) DeleteTag(c echo.Context) error {
 	userID := c.Get("user_id").(string)
 	tagID := c.Param("id")
 
-	notesCount, err := h.service.DeleteTag(c.Request().Context(), userID, tagID)
+	// Options come from the query string: ?mode=reassign&target_tag_id=...&dry_run=true
+	dryRun, _ := strconv.ParseBool(c.QueryParam("dry_run"))
+	req := DeleteTagRequest{
+		Mode:        c.QueryParam("mode"),
+		TargetTagID: c.QueryParam("target_tag_id"),
+		DryRun:      dryRun,
+	}
+
+	response, err := h.service.DeleteTag(c.Request().Context(), userID, tagID, req)
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{
 			"error": err.Error(),
 		})
 	}
 
+	return c.JSON(http.StatusOK, response)
+}
+
+// ListTrash returns the user's trashed notes
+func (h *Handler) ListTrash(c echo.Context) error {
+	userID := c.Get("user_id").(string)
+
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	perPage, _ := strconv.Atoi(c.QueryParam("per_page"))
+
+	response, err := h.service.ListTrash(c.Request().Context(), userID, page, perPage)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// RestoreNote takes a note out of the trash
+func (h *Handler) RestoreNote(c echo.Context) error {
+	userID := c.Get("user_id").(string)
+	noteID := c.Param("id")
+
+	note, err := h.service.RestoreNote(c.Request().Context(), userID, noteID)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(http.StatusOK, note)
+}
+
+// DeleteNotePermanently removes a trashed note for good
+func (h *Handler) DeleteNotePermanently(c echo.Context) error {
+	userID := c.Get("user_id").(string)
+	noteID := c.Param("id")
+
+	if err := h.service.DeleteNotePermanently(c.Request().Context(), userID, noteID); err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// EmptyTrash removes all trashed notes for good
+func (h *Handler) EmptyTrash(c echo.Context) error {
+	userID := c.Get("user_id").(string)
+
+	deleted, err := h.service.EmptyTrash(c.Request().Context(), userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"message":       "Tag and associated notes deleted successfully",
-		"notes_deleted": notesCount,
+		"notes_deleted": deleted,
 	})
 }
 
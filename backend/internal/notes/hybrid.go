@@ -0,0 +1,70 @@
+package notes
+
+import "sort"
+
+// HybridSearchConfig weights the full-text and vector rankings that hybrid search fuses
+type HybridSearchConfig struct {
+	LexicalWeight  float64
+	SemanticWeight float64
+	RRFK           int // Reciprocal rank fusion constant; larger values give lower ranks more influence
+}
+
+// fuseRankings merges a full-text and a vector ranking with weighted reciprocal rank fusion:
+// each note scores weight / (k + rank) in every ranking it appears in. Both inputs must be
+// ordered best first; the result is ordered by fused score and cut to limit.
+func fuseRankings(lexical, semantic []SearchResult, cfg HybridSearchConfig, limit int) []SearchResult {
+	byID := map[string]*SearchResult{}
+	order := []string{}
+
+	merge := func(results []SearchResult, weight float64, lexicalRanking bool) {
+		for i, result := range results {
+			rank := i + 1
+			score := result.Score
+
+			fused, ok := byID[result.NoteID]
+			if !ok {
+				fused = &SearchResult{NoteID: result.NoteID, Title: result.Title, FusedScore: new(float64)}
+				byID[result.NoteID] = fused
+				order = append(order, result.NoteID)
+			}
+			if fused.Title == "" {
+				fused.Title = result.Title
+			}
+			if len(fused.Snippets) == 0 {
+				fused.Snippets = result.Snippets // Full-text headlines are merged first and win
+			}
+
+			if lexicalRanking {
+				fused.LexicalScore, fused.LexicalRank = &score, &rank
+			} else {
+				fused.SemanticScore, fused.SemanticRank = &score, &rank
+				fused.Passage, fused.MatchedChunks = result.Passage, result.MatchedChunks
+			}
+			*fused.FusedScore += weight / float64(cfg.RRFK+rank)
+		}
+	}
+
+	merge(lexical, cfg.LexicalWeight, true)
+	merge(semantic, cfg.SemanticWeight, false)
+
+	results := make([]SearchResult, 0, len(order))
+	for _, id := range order {
+		result := byID[id]
+		result.Score = float32(*result.FusedScore)
+		results = append(results, *result)
+	}
+
+	// Ties keep the note ID order so that repeated searches return the same list
+	sort.SliceStable(results, func(i, j int) bool {
+		if *results[i].FusedScore != *results[j].FusedScore {
+			return *results[i].FusedScore > *results[j].FusedScore
+		}
+		return results[i].NoteID < results[j].NoteID
+	})
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results
+}
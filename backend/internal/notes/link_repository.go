@@ -48,7 +48,7 @@ func (r *PostgresLinkRepository) GetBacklinks(ctx context.Context, noteID string
 		SELECT n.id, n.title
 		FROM notes n
 		INNER JOIN note_links nl ON n.id = nl.source_note_id
-		WHERE nl.target_note_id = $1
+		WHERE nl.target_note_id = $1 AND n.deleted_at IS NULL
 		ORDER BY n.title
 	`
 	rows, err := r.db.Query(ctx, query, noteID)
@@ -75,7 +75,7 @@ func (r *PostgresLinkRepository) GetOutlinks(ctx context.Context, noteID string)
 		SELECT n.id, n.title
 		FROM notes n
 		INNER JOIN note_links nl ON n.id = nl.target_note_id
-		WHERE nl.source_note_id = $1
+		WHERE nl.source_note_id = $1 AND n.deleted_at IS NULL
 		ORDER BY n.title
 	`
 	rows, err := r.db.Query(ctx, query, noteID)
@@ -102,7 +102,7 @@ func (r *PostgresLinkRepository) GetAllLinksForUser(ctx context.Context, userID
 	notesQuery := `
 		SELECT id, title
 		FROM notes
-		WHERE user_id = $1
+		WHERE user_id = $1 AND deleted_at IS NULL
 		ORDER BY title
 	`
 	rows, err := r.db.Query(ctx, notesQuery, userID)
@@ -117,7 +117,6 @@ func (r *PostgresLinkRepository) GetAllLinksForUser(ctx context.Context, userID
 		if err := rows.Scan(&node.ID, &node.Title); err != nil {
 			return nil, nil, fmt.Errorf("failed to scan node: %w", err)
 		}
-		node.Group = 1 // Default group
 		nodes = append(nodes, node)
 	}
 
@@ -128,6 +127,7 @@ func (r *PostgresLinkRepository) GetAllLinksForUser(ctx context.Context, userID
 		INNER JOIN notes n1 ON nl.source_note_id = n1.id
 		INNER JOIN notes n2 ON nl.target_note_id = n2.id
 		WHERE n1.user_id = $1 AND n2.user_id = $1
+		  AND n1.deleted_at IS NULL AND n2.deleted_at IS NULL
 	`
 	linkRows, err := r.db.Query(ctx, linksQuery, userID)
 	if err != nil {
@@ -152,7 +152,7 @@ func (r *PostgresLinkRepository) FindNoteByTitle(ctx context.Context, userID, ti
 	var noteID string
 	query := `
 		SELECT id FROM notes
-		WHERE user_id = $1 AND LOWER(title) = LOWER($2)
+		WHERE user_id = $1 AND LOWER(title) = LOWER($2) AND deleted_at IS NULL
 		LIMIT 1
 	`
 	err := r.db.QueryRow(ctx, query, userID, title).Scan(&noteID)
@@ -14,14 +14,115 @@ type Note struct {
 	SharedAt   *time.Time `json:"shared_at,omitempty"`
 	CreatedAt  time.Time  `json:"created_at"`
 	UpdatedAt  time.Time  `json:"updated_at"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"` // Set while the note is in the trash
+	Version    int        `json:"version,omitempty"`    // Latest version number; only set when a single note is returned
+	Rank       *float32   `json:"rank,omitempty"`       // Full-text relevance; only set when sorting by relevance
+	Snippets   []Snippet  `json:"snippets,omitempty"`   // Matching excerpts; only set when listing with a search
 	Tags       []string   `json:"tags,omitempty"`
 }
 
 type Tag struct {
-	ID        string    `json:"id"`
-	UserID    string    `json:"user_id"`
-	Name      string    `json:"name"`
-	CreatedAt time.Time `json:"created_at"`
+	ID          string    `json:"id"`
+	UserID      string    `json:"user_id"`
+	Name        string    `json:"name"` // Full path, e.g. "lang/go/concurrency"
+	ParentID    *string   `json:"parent_id,omitempty"`
+	Color       *string   `json:"color,omitempty"` // Hex colour, e.g. "#3b82f6"
+	Icon        *string   `json:"icon,omitempty"`
+	Description *string   `json:"description,omitempty"`
+	Pinned      bool      `json:"pinned"` // Preferred when choosing the tag that colours a note
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// TagNode is a tag in the tag tree
+type TagNode struct {
+	Tag
+	NoteCount      int        `json:"note_count"`       // Notes carrying this tag
+	TotalNoteCount int        `json:"total_note_count"` // Notes carrying this tag or any descendant
+	Children       []*TagNode `json:"children"`
+}
+
+type ListTagsResponse struct {
+	Tags []Tag      `json:"tags"`
+	Tree []*TagNode `json:"tree"`
+}
+
+// Tag delete modes
+const (
+	TagDeleteDetach   = "detach"   // Remove the tag from its notes and keep the notes
+	TagDeleteCascade  = "cascade"  // Move the tagged notes to the trash
+	TagDeleteReassign = "reassign" // Move the notes to another tag
+)
+
+type DeleteTagRequest struct {
+	Mode        string `json:"mode"`
+	TargetTagID string `json:"target_tag_id,omitempty"` // Required for reassign
+	DryRun      bool   `json:"dry_run"`                 // Only preview the affected notes
+}
+
+type DeleteTagResponse struct {
+	Tag           Tag          `json:"tag"`
+	Mode          string       `json:"mode"`
+	TargetTag     *Tag         `json:"target_tag,omitempty"`
+	DryRun        bool         `json:"dry_run"`
+	AffectedNotes []LinkedNote `json:"affected_notes"`
+}
+
+// UpdateTagRequest changes the given fields; empty strings clear colour, icon and description
+type UpdateTagRequest struct {
+	Name        *string `json:"name,omitempty"`
+	Color       *string `json:"color,omitempty"`
+	Icon        *string `json:"icon,omitempty"`
+	Description *string `json:"description,omitempty"`
+	Pinned      *bool   `json:"pinned,omitempty"`
+}
+
+type MergeTagsRequest struct {
+	SourceTagIDs []string `json:"source_tag_ids"` // Tags folded into the target and then deleted
+	TargetTagID  string   `json:"target_tag_id"`
+}
+
+type MergeTagsResponse struct {
+	Tag          Tag   `json:"tag"`
+	MergedTags   []Tag `json:"merged_tags"`
+	NotesUpdated int   `json:"notes_updated"`
+}
+
+type TagPair struct {
+	TagAID string  `json:"tag_a_id"`
+	TagA   string  `json:"tag_a"`
+	TagBID string  `json:"tag_b_id"`
+	TagB   string  `json:"tag_b"`
+	Count  int     `json:"count"` // Notes carrying both tags
+	Lift   float64 `json:"lift"`  // Above 1 when the tags appear together more often than by chance
+}
+
+type WeekCount struct {
+	Week  time.Time `json:"week"` // Start of the week (Monday)
+	Count int       `json:"count"`
+}
+
+type TagUsage struct {
+	TagID string      `json:"tag_id"`
+	Tag   string      `json:"tag"`
+	Weeks []WeekCount `json:"weeks"` // Notes tagged per week, oldest first
+}
+
+type TagMergeSuggestion struct {
+	SourceTagID  string  `json:"source_tag_id"`
+	SourceTag    string  `json:"source_tag"`
+	TargetTagID  string  `json:"target_tag_id"` // The tag with more notes
+	TargetTag    string  `json:"target_tag"`
+	Similarity   float64 `json:"similarity"` // Name similarity from 0 to 1
+	CoOccurrence int     `json:"co_occurrence"`
+	Reason       string  `json:"reason"`
+}
+
+type TagAnalyticsResponse struct {
+	TotalNotes       int                  `json:"total_notes"`
+	CoOccurrences    []TagPair            `json:"co_occurrences"`
+	Usage            []TagUsage           `json:"usage"`
+	OrphanedTags     []Tag                `json:"orphaned_tags"` // Tags without notes, including their descendants
+	MergeSuggestions []TagMergeSuggestion `json:"merge_suggestions"`
 }
 
 type CreateNoteRequest struct {
@@ -29,6 +130,14 @@ type CreateNoteRequest struct {
 	ContentMd string   `json:"content_md"`
 	SourceURL *string  `json:"source_url,omitempty"`
 	Tags      []string `json:"tags,omitempty"`
+	IsPublic  *bool    `json:"is_public,omitempty"` // Defaults to the user's default_visibility preference
+}
+
+// Preferences are the per-user settings the notes service honours
+type Preferences struct {
+	PageSize        int    // Default page size when per_page is not given
+	SearchLanguage  string // Text search configuration for full-text filters
+	PublicByDefault bool   // Share new notes unless the request says otherwise
 }
 
 type UpdateNoteRequest struct {
@@ -38,11 +147,70 @@ type UpdateNoteRequest struct {
 	Tags      []string `json:"tags,omitempty"`
 }
 
+// Note list sort fields
+const (
+	NoteSortCreatedAt = "created_at"
+	NoteSortUpdatedAt = "updated_at"
+	NoteSortTitle     = "title"
+	NoteSortRelevance = "relevance" // Requires search or words in q
+)
+
+// NoteListOptions selects, sorts and pages the notes returned by NoteRepository.List
+type NoteListOptions struct {
+	TagIDs        []string   // Any of these tags
+	AllTagIDs     [][]string // Every group must match; a group is a tag and its descendants
+	ExcludeTagIDs []string
+	Untagged      bool
+	CreatedAfter  *time.Time // Inclusive
+	CreatedBefore *time.Time // Exclusive
+	UpdatedAfter  *time.Time
+	UpdatedBefore *time.Time
+	HasSource     *bool
+	SourceDomain  string // Matches the domain and its subdomains
+	IsPublic      *bool
+	Search        string
+	Query         *SearchQuery // Parsed search query language, see ParseQuery
+	Language      string       // Text search configuration for Search and Query
+	Sort          string       // One of the NoteSort constants
+	Desc          bool
+	After         *NoteCursor // Keyset position; Offset is ignored when set
+	Offset        int
+	Limit         int
+}
+
+// ListNotesRequest filters, sorts and pages notes. It is read from the query string of
+// GET /api/notes and from the JSON body of POST /api/notes/query. All filters combine with AND.
 type ListNotesRequest struct {
-	Page    int      `json:"page"`
-	PerPage int      `json:"per_page"`
-	Tags    []string `json:"tags,omitempty"`
-	Search  string   `json:"search,omitempty"`
+	Page        int      `json:"page"`
+	PerPage     int      `json:"per_page"`
+	Tags        []string `json:"tags,omitempty"`         // Notes with any of these tags
+	AllTags     []string `json:"all_tags,omitempty"`     // Notes with every one of these tags
+	ExcludeTags []string `json:"exclude_tags,omitempty"` // Notes with none of these tags
+	Untagged    bool     `json:"untagged,omitempty"`     // Notes without tags
+	Search      string   `json:"search,omitempty"`
+	Query       string   `json:"q,omitempty"` // e.g. tag:go title:"context" -draft before:2025-01-01, see ParseQuery
+	// Dates are RFC 3339 timestamps or YYYY-MM-DD; "after" is inclusive, "before" exclusive
+	CreatedAfter  string `json:"created_after,omitempty"`
+	CreatedBefore string `json:"created_before,omitempty"`
+	UpdatedAfter  string `json:"updated_after,omitempty"`
+	UpdatedBefore string `json:"updated_before,omitempty"`
+	HasSource     *bool  `json:"has_source,omitempty"`
+	SourceDomain  string `json:"source_domain,omitempty"` // e.g. "github.com", also matches subdomains
+	IsPublic      *bool  `json:"is_public,omitempty"`
+	// Also match notes carrying a descendant of the given tags
+	IncludeDescendants bool   `json:"include_descendants,omitempty"`
+	Sort               string `json:"sort,omitempty"`   // created_at (default), updated_at, title or relevance
+	Order              string `json:"order,omitempty"`  // asc or desc; title defaults to asc, the others to desc
+	Cursor             string `json:"cursor,omitempty"` // next_cursor of the previous page; replaces page
+}
+
+// ListRequestError is returned when a ListNotesRequest is invalid, as opposed to a failure to list
+type ListRequestError struct {
+	Message string
+}
+
+func (e *ListRequestError) Error() string {
+	return e.Message
 }
 
 type ListNotesResponse struct {
@@ -51,6 +219,16 @@ type ListNotesResponse struct {
 	Page       int    `json:"page"`
 	PerPage    int    `json:"per_page"`
 	TotalPages int    `json:"total_pages"`
+	NextCursor string `json:"next_cursor,omitempty"` // Empty on the last page
+}
+
+type ListTrashResponse struct {
+	Notes         []Note `json:"notes"`
+	Total         int    `json:"total"`
+	Page          int    `json:"page"`
+	PerPage       int    `json:"per_page"`
+	TotalPages    int    `json:"total_pages"`
+	RetentionDays int    `json:"retention_days"` // Trashed notes are purged after this many days
 }
 
 type StatsResponse struct {
@@ -58,21 +236,48 @@ type StatsResponse struct {
 	TagsCount  int `json:"tags_count"`
 }
 
+// Search modes
+const (
+	SearchModeSemantic = "semantic" // Vector similarity only
+	SearchModeHybrid   = "hybrid"   // Full-text and vector rankings fused with reciprocal rank fusion
+)
+
 type SearchRequest struct {
 	Query  string `json:"query"`
 	Limit  int    `json:"limit,omitempty"`
-	UserID string `json:"-"` // Set from JWT, not from request body
+	Mode   string `json:"mode,omitempty"` // semantic (default) or hybrid
+	UserID string `json:"-"`              // Set from JWT, not from request body
 }
 
 type SearchResult struct {
 	NoteID string  `json:"note_id"`
 	Title  string  `json:"title"`
-	Score  float32 `json:"score"`
+	Score  float32 `json:"score"` // Vector similarity, or the fused score in hybrid mode
+	// Hybrid mode only. Scores and 1-based ranks are missing when that search did not find the note.
+	LexicalScore  *float32  `json:"lexical_score,omitempty"`
+	LexicalRank   *int      `json:"lexical_rank,omitempty"`
+	SemanticScore *float32  `json:"semantic_score,omitempty"`
+	SemanticRank  *int      `json:"semantic_rank,omitempty"`
+	FusedScore    *float64  `json:"fused_score,omitempty"`
+	Snippets      []Snippet `json:"snippets"` // Full-text headlines, or the passage closest to the query
+	// Vector search only: the chunk of the note closest to the query, and how many chunks matched
+	Passage       *Passage `json:"passage,omitempty"`
+	MatchedChunks int      `json:"matched_chunks,omitempty"`
+}
+
+// Passage is a chunk of a note found by vector search
+type Passage struct {
+	ChunkIndex int     `json:"chunk_index"`
+	Start      int     `json:"start"` // Character offset in content_md
+	End        int     `json:"end"`   // Exclusive
+	Text       string  `json:"text"`
+	Score      float32 `json:"score"`
 }
 
 type SearchResponse struct {
 	Results []SearchResult `json:"results"`
 	Query   string         `json:"query"`
+	Mode    string         `json:"mode"`
 }
 
 type VectorPoint struct {
@@ -80,6 +285,9 @@ type VectorPoint struct {
 	Title     string    `json:"title"`
 	Vector    []float32 `json:"vector"`
 	Tags      []string  `json:"tags,omitempty"`
+	Group     int       `json:"group"`           // Group of the primary tag, 0 when untagged
+	Tag       *string   `json:"tag,omitempty"`   // Primary tag
+	Color     *string   `json:"color,omitempty"` // Colour of the primary tag
 	CreatedAt time.Time `json:"created_at"`
 }
 
@@ -106,9 +314,11 @@ type BacklinksResponse struct {
 }
 
 type GraphNode struct {
-	ID    string `json:"id"`
-	Title string `json:"title"`
-	Group int    `json:"group"` // For coloring by tag/category
+	ID    string  `json:"id"`
+	Title string  `json:"title"`
+	Group int     `json:"group"`           // Group of the primary tag, 0 when untagged
+	Tag   *string `json:"tag,omitempty"`   // Primary tag
+	Color *string `json:"color,omitempty"` // Colour of the primary tag
 }
 
 type GraphLink struct {
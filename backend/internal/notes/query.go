@@ -0,0 +1,522 @@
+package notes
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Search query grammar:
+//
+//	query   = or
+//	or      = and { "OR" and }
+//	and     = unary { unary }             terms next to each other must all match
+//	unary   = "-" unary | primary          "-" negates the term that follows it
+//	primary = "(" or ")" | field | text
+//	field   = name ":" value
+//	text    = word | "\"" phrase "\""
+//
+// Fields:
+//
+//	tag:go                 note has the tag or one of its descendants
+//	title:"context"        title contains the text
+//	source:github.com      source URL contains the text
+//	created:2025-01-01     also created:>D, created:>=D, created:<D, created:<=D
+//	updated:2025-01-01     same operators as created
+//	before:D, after:D      created before / after the day
+//	is:public, is:private
+//
+// Words and phrases are matched with full-text search. "OR" must be upper case. A word
+// whose text before the colon is not a field, such as a URL, ERR:1234 or 12:30, is a word.
+
+// maxQueryLength bounds the size of a search query
+const maxQueryLength = 1000
+
+// queryFields are the names that can be used as name:value
+var queryFields = []string{"tag", "title", "source", "created", "updated", "before", "after", "is"}
+
+// QueryError describes an invalid search query
+type QueryError struct {
+	Position int    // Byte offset of the problem in the query
+	Message  string // What is wrong
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("invalid query at position %d: %s", e.Position, e.Message)
+}
+
+// SearchQuery is a parsed search query
+type SearchQuery struct {
+	root queryNode
+}
+
+// ParseQuery parses a search query. An empty query returns nil.
+func ParseQuery(input string) (*SearchQuery, error) {
+	if len(input) > maxQueryLength {
+		return nil, &QueryError{Position: maxQueryLength, Message: fmt.Sprintf("query is longer than %d characters", maxQueryLength)}
+	}
+
+	tokens, err := lexQuery(input)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	p := &queryParser{tokens: tokens, end: len(input)}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok != nil {
+		if tok.kind == tokenRParen {
+			return nil, &QueryError{Position: tok.pos, Message: "unexpected )"}
+		}
+		return nil, &QueryError{Position: tok.pos, Message: fmt.Sprintf("unexpected %q", tok.text)}
+	}
+
+	return &SearchQuery{root: root}, nil
+}
+
+// SQL compiles the query to a boolean SQL condition on the notes table aliased "n".
+// Values are bound as parameters numbered from argPos; language must be a trusted
+// text search configuration because it is inlined.
+func (q *SearchQuery) SQL(language string, argPos int) (string, []interface{}) {
+	c := &queryCompiler{language: language, argPos: argPos}
+	return q.root.sql(c), c.args
+}
+
+// RankText returns the words and phrases that are not negated, for relevance ranking
+func (q *SearchQuery) RankText() string {
+	var terms []string
+	collectRankText(q.root, &terms)
+	return strings.Join(terms, " ")
+}
+
+func collectRankText(node queryNode, terms *[]string) {
+	switch n := node.(type) {
+	case *textNode:
+		*terms = append(*terms, n.text)
+	case *andNode:
+		for _, child := range n.children {
+			collectRankText(child, terms)
+		}
+	case *orNode:
+		for _, child := range n.children {
+			collectRankText(child, terms)
+		}
+	}
+}
+
+// ============================================================
+// Lexer
+// ============================================================
+
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenPhrase
+	tokenField
+	tokenOr
+	tokenNot
+	tokenLParen
+	tokenRParen
+)
+
+type queryToken struct {
+	kind  tokenKind
+	pos   int
+	text  string // Word, phrase or field value
+	field string // Field name for tokenField
+}
+
+func lexQuery(input string) ([]queryToken, error) {
+	tokens := []queryToken{}
+	i := 0
+
+	for i < len(input) {
+		ch := input[i]
+
+		switch {
+		case unicode.IsSpace(rune(ch)):
+			i++
+
+		case ch == '(':
+			tokens = append(tokens, queryToken{kind: tokenLParen, pos: i, text: "("})
+			i++
+
+		case ch == ')':
+			tokens = append(tokens, queryToken{kind: tokenRParen, pos: i, text: ")"})
+			i++
+
+		case ch == '-':
+			if i+1 == len(input) || unicode.IsSpace(rune(input[i+1])) || input[i+1] == ')' {
+				return nil, &QueryError{Position: i, Message: "nothing to negate after -"}
+			}
+			tokens = append(tokens, queryToken{kind: tokenNot, pos: i, text: "-"})
+			i++
+
+		case ch == '"':
+			phrase, next, err := lexQuoted(input, i)
+			if err != nil {
+				return nil, err
+			}
+			if strings.TrimSpace(phrase) == "" {
+				return nil, &QueryError{Position: i, Message: "empty phrase"}
+			}
+			tokens = append(tokens, queryToken{kind: tokenPhrase, pos: i, text: phrase})
+			i = next
+
+		default:
+			start := i
+			for i < len(input) && !unicode.IsSpace(rune(input[i])) && !strings.ContainsRune(`()"`, rune(input[i])) {
+				i++
+			}
+			word := input[start:i]
+
+			colon := strings.IndexByte(word, ':')
+			if colon < 0 || !slices.Contains(queryFields, strings.ToLower(word[:colon])) {
+				if word == "OR" {
+					tokens = append(tokens, queryToken{kind: tokenOr, pos: start, text: word})
+				} else {
+					tokens = append(tokens, queryToken{kind: tokenWord, pos: start, text: word})
+				}
+				continue
+			}
+
+			field := strings.ToLower(word[:colon])
+			value := word[colon+1:]
+
+			// A quoted value directly after the colon: title:"two words"
+			if value == "" && i < len(input) && input[i] == '"' {
+				quoted, next, err := lexQuoted(input, i)
+				if err != nil {
+					return nil, err
+				}
+				value = quoted
+				i = next
+			}
+
+			if value == "" {
+				return nil, &QueryError{Position: start, Message: fmt.Sprintf("%s: needs a value", field)}
+			}
+
+			tokens = append(tokens, queryToken{kind: tokenField, pos: start, field: field, text: value})
+		}
+	}
+
+	return tokens, nil
+}
+
+// lexQuoted reads a quoted string starting at input[start] == '"' and returns its content
+// and the offset after the closing quote
+func lexQuoted(input string, start int) (string, int, error) {
+	end := strings.IndexByte(input[start+1:], '"')
+	if end < 0 {
+		return "", 0, &QueryError{Position: start, Message: "missing closing quote"}
+	}
+	return input[start+1 : start+1+end], start + end + 2, nil
+}
+
+// ============================================================
+// Parser
+// ============================================================
+
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+	end    int // Length of the input, for errors at the end of the query
+}
+
+func (p *queryParser) peek() *queryToken {
+	if p.pos >= len(p.tokens) {
+		return nil
+	}
+	return &p.tokens[p.pos]
+}
+
+func (p *queryParser) parseOr() (queryNode, error) {
+	first, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	children := []queryNode{first}
+	for tok := p.peek(); tok != nil && tok.kind == tokenOr; tok = p.peek() {
+		p.pos++
+		next, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, next)
+	}
+
+	if len(children) == 1 {
+		return first, nil
+	}
+	return &orNode{children: children}, nil
+}
+
+func (p *queryParser) parseAnd() (queryNode, error) {
+	children := []queryNode{}
+	for tok := p.peek(); tok != nil && tok.kind != tokenOr && tok.kind != tokenRParen; tok = p.peek() {
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, node)
+	}
+
+	if len(children) == 0 {
+		if tok := p.peek(); tok != nil {
+			return nil, &QueryError{Position: tok.pos, Message: fmt.Sprintf("expected a term before %q", tok.text)}
+		}
+		return nil, &QueryError{Position: p.end, Message: "expected a term at the end of the query"}
+	}
+
+	if len(children) == 1 {
+		return children[0], nil
+	}
+	return &andNode{children: children}, nil
+}
+
+func (p *queryParser) parseUnary() (queryNode, error) {
+	tok := p.peek()
+	if tok.kind != tokenNot {
+		return p.parsePrimary()
+	}
+
+	p.pos++
+	if next := p.peek(); next == nil || next.kind == tokenOr {
+		return nil, &QueryError{Position: tok.pos, Message: "nothing to negate after -"}
+	}
+
+	child, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	return &notNode{child: child}, nil
+}
+
+func (p *queryParser) parsePrimary() (queryNode, error) {
+	tok := p.peek()
+	p.pos++
+
+	switch tok.kind {
+	case tokenLParen:
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		closing := p.peek()
+		if closing == nil || closing.kind != tokenRParen {
+			return nil, &QueryError{Position: tok.pos, Message: "missing closing )"}
+		}
+		p.pos++
+		return node, nil
+
+	case tokenWord:
+		return &textNode{text: tok.text}, nil
+
+	case tokenPhrase:
+		return &textNode{text: tok.text, phrase: true}, nil
+
+	case tokenField:
+		return parseField(tok)
+	}
+
+	return nil, &QueryError{Position: tok.pos, Message: fmt.Sprintf("unexpected %q", tok.text)}
+}
+
+// parseField validates a field:value term
+func parseField(tok *queryToken) (queryNode, error) {
+	switch tok.field {
+	case "tag":
+		name := normalizeTagPath(tok.text)
+		if name == "" {
+			return nil, &QueryError{Position: tok.pos, Message: "tag: needs a tag name"}
+		}
+		return &tagNode{name: name}, nil
+
+	case "title":
+		return &containsNode{column: "n.title", text: tok.text}, nil
+
+	case "source":
+		return &containsNode{column: "COALESCE(n.source_url, '')", text: tok.text}, nil
+
+	case "created", "updated":
+		return parseDateTerm(tok, tok.field+"_at", tok.text)
+
+	case "before":
+		return parseDateTerm(tok, "created_at", "<"+tok.text)
+
+	case "after":
+		return parseDateTerm(tok, "created_at", ">"+tok.text)
+
+	case "is":
+		switch strings.ToLower(tok.text) {
+		case "public":
+			return &rawNode{condition: "n.is_public"}, nil
+		case "private":
+			return &rawNode{condition: "NOT n.is_public"}, nil
+		}
+		return nil, &QueryError{Position: tok.pos, Message: "is: must be public or private"}
+	}
+
+	return nil, &QueryError{
+		Position: tok.pos,
+		Message:  fmt.Sprintf("unknown field %q (use tag, title, source, created, updated, before, after or is)", tok.field),
+	}
+}
+
+// parseDateTerm turns an optional comparison and a YYYY-MM-DD day into a half-open time range
+func parseDateTerm(tok *queryToken, column, value string) (queryNode, error) {
+	op := ""
+	for _, candidate := range []string{">=", "<=", ">", "<"} {
+		if strings.HasPrefix(value, candidate) {
+			op = candidate
+			break
+		}
+	}
+
+	day, err := time.Parse(time.DateOnly, value[len(op):])
+	if err != nil {
+		return nil, &QueryError{Position: tok.pos, Message: fmt.Sprintf("%s: expects a date like 2025-01-01", tok.field)}
+	}
+	nextDay := day.AddDate(0, 0, 1)
+
+	node := &dateNode{column: "n." + column}
+	switch op {
+	case "":
+		node.from, node.to = &day, &nextDay
+	case ">":
+		node.from = &nextDay
+	case ">=":
+		node.from = &day
+	case "<":
+		node.to = &day
+	case "<=":
+		node.to = &nextDay
+	}
+
+	return node, nil
+}
+
+// ============================================================
+// SQL compilation
+// ============================================================
+
+type queryCompiler struct {
+	language string
+	args     []interface{}
+	argPos   int
+}
+
+// bind adds a parameter and returns its placeholder
+func (c *queryCompiler) bind(value interface{}) string {
+	c.args = append(c.args, value)
+	placeholder := fmt.Sprintf("$%d", c.argPos)
+	c.argPos++
+	return placeholder
+}
+
+type queryNode interface {
+	sql(c *queryCompiler) string
+}
+
+type andNode struct{ children []queryNode }
+
+func (n *andNode) sql(c *queryCompiler) string {
+	parts := make([]string, len(n.children))
+	for i, child := range n.children {
+		parts[i] = child.sql(c)
+	}
+	return "(" + strings.Join(parts, " AND ") + ")"
+}
+
+type orNode struct{ children []queryNode }
+
+func (n *orNode) sql(c *queryCompiler) string {
+	parts := make([]string, len(n.children))
+	for i, child := range n.children {
+		parts[i] = child.sql(c)
+	}
+	return "(" + strings.Join(parts, " OR ") + ")"
+}
+
+type notNode struct{ child queryNode }
+
+func (n *notNode) sql(c *queryCompiler) string {
+	return "NOT " + n.child.sql(c)
+}
+
+// textNode is a full-text word or phrase; the expression matches the idx_notes_search index
+type textNode struct {
+	text   string
+	phrase bool
+}
+
+func (n *textNode) sql(c *queryCompiler) string {
+	function := "plainto_tsquery"
+	if n.phrase {
+		function = "phraseto_tsquery"
+	}
+	return fmt.Sprintf(
+		"(to_tsvector('%[1]s', n.title || ' ' || n.content_md) @@ %[2]s('%[1]s', %[3]s))",
+		c.language, function, c.bind(n.text),
+	)
+}
+
+// tagNode matches notes with the tag or one of its descendants
+type tagNode struct{ name string }
+
+func (n *tagNode) sql(c *queryCompiler) string {
+	name := c.bind(n.name)
+	return fmt.Sprintf(`EXISTS (
+		SELECT 1 FROM note_tags nt
+		INNER JOIN tags t ON t.id = nt.tag_id
+		WHERE nt.note_id = n.id AND (t.name = %[1]s OR left(t.name, length(%[1]s) + 1) = %[1]s || '/')
+	)`, name)
+}
+
+// containsNode is a case-insensitive substring match on a column
+type containsNode struct {
+	column string
+	text   string
+}
+
+func (n *containsNode) sql(c *queryCompiler) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(n.text)
+	return fmt.Sprintf("(%s ILIKE %s)", n.column, c.bind("%"+escaped+"%"))
+}
+
+// dateNode is a half-open time range [from, to) on a timestamp column
+type dateNode struct {
+	column string
+	from   *time.Time
+	to     *time.Time
+}
+
+func (n *dateNode) sql(c *queryCompiler) string {
+	parts := []string{}
+	if n.from != nil {
+		parts = append(parts, fmt.Sprintf("%s >= %s", n.column, c.bind(*n.from)))
+	}
+	if n.to != nil {
+		parts = append(parts, fmt.Sprintf("%s < %s", n.column, c.bind(*n.to)))
+	}
+	return "(" + strings.Join(parts, " AND ") + ")"
+}
+
+// rawNode is a fixed condition without parameters
+type rawNode struct{ condition string }
+
+func (n *rawNode) sql(c *queryCompiler) string {
+	return "(" + n.condition + ")"
+}
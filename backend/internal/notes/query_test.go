@@ -0,0 +1,156 @@
+package notes
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseQuerySQL(t *testing.T) {
+	text := func(function string, arg int) string {
+		return fmt.Sprintf("(to_tsvector('english', n.title || ' ' || n.content_md) @@ %s('english', $%d))", function, arg)
+	}
+	word := func(arg int) string { return text("plainto_tsquery", arg) }
+	tag := func(arg int) string {
+		return fmt.Sprintf(`EXISTS (
+		SELECT 1 FROM note_tags nt
+		INNER JOIN tags t ON t.id = nt.tag_id
+		WHERE nt.note_id = n.id AND (t.name = $%[1]d OR left(t.name, length($%[1]d) + 1) = $%[1]d || '/')
+	)`, arg)
+	}
+	day := func(value string) time.Time {
+		d, _ := time.Parse(time.DateOnly, value)
+		return d
+	}
+
+	tests := []struct {
+		query string
+		sql   string
+		args  []interface{}
+	}{
+		{`go`, word(3), []interface{}{"go"}},
+		{`go rust`, "(" + word(3) + " AND " + word(4) + ")", []interface{}{"go", "rust"}},
+		// AND binds tighter than OR
+		{`go OR rust docs`, "(" + word(3) + " OR (" + word(4) + " AND " + word(5) + "))", []interface{}{"go", "rust", "docs"}},
+		{`(go OR rust) docs`, "((" + word(3) + " OR " + word(4) + ") AND " + word(5) + ")", []interface{}{"go", "rust", "docs"}},
+		{`-draft`, "NOT " + word(3), []interface{}{"draft"}},
+		{`-(a OR b)`, "NOT (" + word(3) + " OR " + word(4) + ")", []interface{}{"a", "b"}},
+		{`--a`, "NOT NOT " + word(3), []interface{}{"a"}},
+		{`"hello world"`, text("phraseto_tsquery", 3), []interface{}{"hello world"}},
+		{`title:"two words"`, "(n.title ILIKE $3)", []interface{}{"%two words%"}},
+		{`TITLE:context`, "(n.title ILIKE $3)", []interface{}{"%context%"}},
+		{`source:50%_x\y`, "(COALESCE(n.source_url, '') ILIKE $3)", []interface{}{`%50\%\_x\\y%`}},
+		{`tag:Lang/Go`, tag(3), []interface{}{"lang/go"}},
+		{`created:2025-01-01`, "(n.created_at >= $3 AND n.created_at < $4)", []interface{}{day("2025-01-01"), day("2025-01-02")}},
+		{`updated:>2025-01-01`, "(n.updated_at >= $3)", []interface{}{day("2025-01-02")}},
+		{`updated:>=2025-01-01`, "(n.updated_at >= $3)", []interface{}{day("2025-01-01")}},
+		{`created:<2025-01-01`, "(n.created_at < $3)", []interface{}{day("2025-01-01")}},
+		{`created:<=2025-01-01`, "(n.created_at < $3)", []interface{}{day("2025-01-02")}},
+		{`before:2025-01-01`, "(n.created_at < $3)", []interface{}{day("2025-01-01")}},
+		{`after:2025-01-01`, "(n.created_at >= $3)", []interface{}{day("2025-01-02")}},
+		{`is:public`, "(n.is_public)", nil},
+		{`is:Private`, "(NOT n.is_public)", nil},
+		// Words whose prefix is not a field stay words
+		{`https://github.com/x ERR:1234 12:30`, "(" + word(3) + " AND " + word(4) + " AND " + word(5) + ")",
+			[]interface{}{"https://github.com/x", "ERR:1234", "12:30"}},
+		{`x-ray`, word(3), []interface{}{"x-ray"}},
+		{
+			`tag:go title:"context" -draft before:2025-01-01`,
+			"(" + tag(3) + " AND (n.title ILIKE $4) AND NOT " + word(5) + " AND (n.created_at < $6))",
+			[]interface{}{"go", "%context%", "draft", day("2025-01-01")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			query, err := ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("ParseQuery(%q) returned error: %v", tt.query, err)
+			}
+
+			sql, args := query.SQL("english", 3)
+			if sql != tt.sql {
+				t.Errorf("SQL:\n got: %s\nwant: %s", sql, tt.sql)
+			}
+			if !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("args: got %#v, want %#v", args, tt.args)
+			}
+		})
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	tests := []struct {
+		query    string
+		position int
+		message  string
+	}{
+		{`title:"unterminated`, 6, "missing closing quote"},
+		{`a "b`, 2, "missing closing quote"},
+		{`""`, 0, "empty phrase"},
+		{`(a b`, 0, "missing closing )"},
+		{`a (b OR c`, 2, "missing closing )"},
+		{`a )`, 2, "unexpected )"},
+		{`()`, 1, `expected a term before ")"`},
+		{`a OR`, 4, "expected a term at the end of the query"},
+		{`OR a`, 0, `expected a term before "OR"`},
+		{`a OR OR b`, 5, `expected a term before "OR"`},
+		{`-`, 0, "nothing to negate after -"},
+		{`a - b`, 2, "nothing to negate after -"},
+		{`-OR a`, 0, "nothing to negate after -"},
+		{`tag:`, 0, "tag: needs a value"},
+		{`go tag:/`, 3, "tag: needs a tag name"},
+		{`created:2024-13-01`, 0, "created: expects a date like 2025-01-01"},
+		{`x before:yesterday`, 2, "before: expects a date like 2025-01-01"},
+		{`is:maybe`, 0, "is: must be public or private"},
+		{strings.Repeat("a", maxQueryLength+1), maxQueryLength, "query is longer than 1000 characters"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			_, err := ParseQuery(tt.query)
+
+			var queryErr *QueryError
+			if !errors.As(err, &queryErr) {
+				t.Fatalf("ParseQuery(%q) error = %v, want a *QueryError", tt.query, err)
+			}
+			if queryErr.Position != tt.position || queryErr.Message != tt.message {
+				t.Errorf("got position %d %q, want position %d %q",
+					queryErr.Position, queryErr.Message, tt.position, tt.message)
+			}
+		})
+	}
+}
+
+func TestParseQueryEmpty(t *testing.T) {
+	for _, input := range []string{"", "   "} {
+		query, err := ParseQuery(input)
+		if query != nil || err != nil {
+			t.Errorf("ParseQuery(%q) = %v, %v; want nil, nil", input, query, err)
+		}
+	}
+}
+
+func TestSearchQueryRankText(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{`go -draft "hello world" OR tag:x`, "go hello world"},
+		{`-(a OR b) title:c`, ""},
+		{`(a OR b) c`, "a b c"},
+	}
+
+	for _, tt := range tests {
+		query, err := ParseQuery(tt.query)
+		if err != nil {
+			t.Fatalf("ParseQuery(%q) returned error: %v", tt.query, err)
+		}
+		if got := query.RankText(); got != tt.want {
+			t.Errorf("RankText(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
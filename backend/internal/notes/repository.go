@@ -3,6 +3,7 @@ package notes
 import (
 	"context"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
@@ -56,7 +57,7 @@ func (r *PostgresNoteRepository) FindByID(ctx context.Context, userID, noteID st
 		SELECT id, user_id, title, content_md, source_url, is_public, public_slug, 
 		       view_count, shared_at, created_at, updated_at
 		FROM notes
-		WHERE id = $1 AND user_id = $2
+		WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
 	`
 
 	err := r.db.QueryRow(ctx, query, noteID, userID).Scan(
@@ -76,7 +77,53 @@ func (r *PostgresNoteRepository) FindByID(ctx context.Context, userID, noteID st
 	return note, nil
 }
 
-func (r *PostgresNoteRepository) Update(ctx context.Context, userID, noteID string, title, contentMd *string, sourceURL *string) (*Note, error) {
+// Update changes the given fields and, when tagIDs is not nil, replaces the note's tags. When
+// expectedVersion is set, the update only happens if the latest version of the note still has
+// that number; otherwise a *ConflictError is returned.
+func (r *PostgresNoteRepository) Update(
+	ctx context.Context,
+	userID, noteID string,
+	title, contentMd *string,
+	sourceURL *string,
+	tagIDs []string,
+	expectedVersion int,
+) (*Note, error) {
+	// Start transaction
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the note so that concurrent writers are checked one after another
+	var id string
+	lockQuery := `SELECT id FROM notes WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL FOR UPDATE`
+	err = tx.QueryRow(ctx, lockQuery, noteID, userID).Scan(&id)
+	if err == pgx.ErrNoRows {
+		return nil, fmt.Errorf("note not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock note: %w", err)
+	}
+
+	currentVersion, err := latestVersionNumber(ctx, tx, noteID)
+	if err != nil {
+		return nil, err
+	}
+
+	if expectedVersion > 0 && currentVersion != expectedVersion {
+		return nil, &ConflictError{BaseVersion: expectedVersion, CurrentVersion: currentVersion}
+	}
+
+	// Change tags before the note so that a version written by the trigger holds the new tags
+	tagsChanged := false
+	if tagIDs != nil {
+		tagsChanged, err = setNoteTags(ctx, tx, noteID, tagIDs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Build dynamic update query
 	updates := []string{}
 	args := []interface{}{noteID, userID}
@@ -100,7 +147,10 @@ func (r *PostgresNoteRepository) Update(ctx context.Context, userID, noteID stri
 		argPos++
 	}
 
-	if len(updates) == 0 {
+	if len(updates) == 0 && !tagsChanged {
+		if err := tx.Commit(ctx); err != nil {
+			return nil, fmt.Errorf("failed to commit transaction: %w", err)
+		}
 		return r.FindByID(ctx, userID, noteID)
 	}
 
@@ -110,13 +160,13 @@ func (r *PostgresNoteRepository) Update(ctx context.Context, userID, noteID stri
 	query := fmt.Sprintf(`
 		UPDATE notes
 		SET %s
-		WHERE id = $1 AND user_id = $2
+		WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
 		RETURNING id, user_id, title, content_md, source_url, is_public, public_slug, 
 		          view_count, shared_at, created_at, updated_at
 	`, strings.Join(updates, ", "))
 
 	note := &Note{}
-	err := r.db.QueryRow(ctx, query, args...).Scan(
+	err = tx.QueryRow(ctx, query, args...).Scan(
 		&note.ID, &note.UserID, &note.Title, &note.ContentMd, &note.SourceURL,
 		&note.IsPublic, &note.PublicSlug, &note.ViewCount, &note.SharedAt,
 		&note.CreatedAt, &note.UpdatedAt,
@@ -130,11 +180,99 @@ func (r *PostgresNoteRepository) Update(ctx context.Context, userID, noteID stri
 		return nil, fmt.Errorf("failed to update note: %w", err)
 	}
 
+	// The note_update_version trigger ignores tag changes, so record their version here
+	if tagsChanged {
+		newVersion, err := latestVersionNumber(ctx, tx, noteID)
+		if err != nil {
+			return nil, err
+		}
+		if newVersion == currentVersion {
+			if err := recordTagVersions(ctx, tx, []string{noteID}, "Tags updated"); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Commit transaction
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return note, nil
+}
+
+// latestVersionNumber returns the newest version number of a note, or 0 if it has none
+func latestVersionNumber(ctx context.Context, tx pgx.Tx, noteID string) (int, error) {
+	var version int
+	query := `SELECT COALESCE(MAX(version_number), 0) FROM note_versions WHERE note_id = $1`
+	if err := tx.QueryRow(ctx, query, noteID).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to get note version: %w", err)
+	}
+	return version, nil
+}
+
+// Trash moves a note to the trash
+func (r *PostgresNoteRepository) Trash(ctx context.Context, userID, noteID string) error {
+	query := `UPDATE notes SET deleted_at = $1 WHERE id = $2 AND user_id = $3 AND deleted_at IS NULL`
+
+	result, err := r.db.Exec(ctx, query, time.Now(), noteID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to trash note: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("note not found")
+	}
+
+	return nil
+}
+
+// TrashState reports whether a note still exists and whether it is in the trash
+func (r *PostgresNoteRepository) TrashState(ctx context.Context, noteID string) (exists, trashed bool, err error) {
+	query := `SELECT deleted_at IS NOT NULL FROM notes WHERE id = $1`
+
+	err = r.db.QueryRow(ctx, query, noteID).Scan(&trashed)
+	if err == pgx.ErrNoRows {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, fmt.Errorf("failed to get note state: %w", err)
+	}
+
+	return true, trashed, nil
+}
+
+// Restore takes a note out of the trash
+func (r *PostgresNoteRepository) Restore(ctx context.Context, userID, noteID string) (*Note, error) {
+	query := `
+		UPDATE notes
+		SET deleted_at = NULL
+		WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL
+		RETURNING id, user_id, title, content_md, source_url, is_public, public_slug,
+		          view_count, shared_at, created_at, updated_at
+	`
+
+	note := &Note{}
+	err := r.db.QueryRow(ctx, query, noteID, userID).Scan(
+		&note.ID, &note.UserID, &note.Title, &note.ContentMd, &note.SourceURL,
+		&note.IsPublic, &note.PublicSlug, &note.ViewCount, &note.SharedAt,
+		&note.CreatedAt, &note.UpdatedAt,
+	)
+
+	if err == pgx.ErrNoRows {
+		return nil, fmt.Errorf("note not found in trash")
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore note: %w", err)
+	}
+
 	return note, nil
 }
 
+// Delete permanently removes a note from the trash (CASCADE removes versions, tags and links)
 func (r *PostgresNoteRepository) Delete(ctx context.Context, userID, noteID string) error {
-	query := `DELETE FROM notes WHERE id = $1 AND user_id = $2`
+	query := `DELETE FROM notes WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL`
 
 	result, err := r.db.Exec(ctx, query, noteID, userID)
 	if err != nil {
@@ -142,40 +280,194 @@ func (r *PostgresNoteRepository) Delete(ctx context.Context, userID, noteID stri
 	}
 
 	if result.RowsAffected() == 0 {
-		return fmt.Errorf("note not found")
+		return fmt.Errorf("note not found in trash")
 	}
 
 	return nil
 }
 
-func (r *PostgresNoteRepository) List(ctx context.Context, userID string, page, perPage int, tagIDs []string, search string) ([]Note, int, error) {
-	offset := (page - 1) * perPage
+// EmptyTrash permanently removes all of the user's trashed notes and returns their IDs
+func (r *PostgresNoteRepository) EmptyTrash(ctx context.Context, userID string) ([]string, error) {
+	query := `DELETE FROM notes WHERE user_id = $1 AND deleted_at IS NOT NULL RETURNING id`
+
+	return r.deleteReturningIDs(ctx, query, userID)
+}
+
+// PurgeTrashed permanently removes notes of all users that were trashed before the
+// given time and returns their IDs
+func (r *PostgresNoteRepository) PurgeTrashed(ctx context.Context, before time.Time) ([]string, error) {
+	query := `DELETE FROM notes WHERE deleted_at < $1 RETURNING id`
+
+	return r.deleteReturningIDs(ctx, query, before)
+}
+
+func (r *PostgresNoteRepository) deleteReturningIDs(ctx context.Context, query string, args ...interface{}) ([]string, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete notes: %w", err)
+	}
+	defer rows.Close()
 
+	noteIDs := []string{}
+	for rows.Next() {
+		var noteID string
+		if err := rows.Scan(&noteID); err != nil {
+			return nil, fmt.Errorf("failed to scan note ID: %w", err)
+		}
+		noteIDs = append(noteIDs, noteID)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to delete notes: %w", err)
+	}
+
+	return noteIDs, nil
+}
+
+// ListTrashed returns a page of the user's trashed notes, most recently trashed first
+func (r *PostgresNoteRepository) ListTrashed(ctx context.Context, userID string, page, perPage int) ([]Note, int, error) {
+	var total int
+	countQuery := `SELECT COUNT(*) FROM notes WHERE user_id = $1 AND deleted_at IS NOT NULL`
+	if err := r.db.QueryRow(ctx, countQuery, userID).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count trashed notes: %w", err)
+	}
+
+	query := `
+		SELECT id, user_id, title, content_md, source_url, is_public, public_slug,
+		       view_count, shared_at, created_at, updated_at, deleted_at
+		FROM notes
+		WHERE user_id = $1 AND deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	rows, err := r.db.Query(ctx, query, userID, perPage, (page-1)*perPage)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list trashed notes: %w", err)
+	}
+	defer rows.Close()
+
+	notes := []Note{}
+	for rows.Next() {
+		var note Note
+		err := rows.Scan(&note.ID, &note.UserID, &note.Title, &note.ContentMd, &note.SourceURL,
+			&note.IsPublic, &note.PublicSlug, &note.ViewCount, &note.SharedAt,
+			&note.CreatedAt, &note.UpdatedAt, &note.DeletedAt)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan note: %w", err)
+		}
+		notes = append(notes, note)
+	}
+
+	return notes, total, nil
+}
+
+// List returns a page of notes and the total number of matching notes.
+// Pages continue after opts.After when it is set, and start at opts.Offset otherwise.
+func (r *PostgresNoteRepository) List(ctx context.Context, userID string, opts NoteListOptions) ([]Note, int, error) {
 	// Build query with filters
-	whereConditions := []string{"n.user_id = $1"}
+	whereConditions := []string{"n.user_id = $1", "n.deleted_at IS NULL"}
 	args := []interface{}{userID}
 	argPos := 2
 
-	// Add tag filter if provided
-	if len(tagIDs) > 0 {
-		whereConditions = append(whereConditions, fmt.Sprintf(`
+	addFilter := func(condition string, value interface{}) {
+		whereConditions = append(whereConditions, fmt.Sprintf(condition, argPos))
+		args = append(args, value)
+		argPos++
+	}
+
+	// Add tag filters if provided
+	if len(opts.TagIDs) > 0 {
+		addFilter(`
 			n.id IN (
 				SELECT note_id FROM note_tags WHERE tag_id = ANY($%d)
 			)
-		`, argPos))
-		args = append(args, tagIDs)
-		argPos++
+		`, opts.TagIDs)
 	}
 
-	// Add search filter if provided
-	if search != "" {
+	for _, group := range opts.AllTagIDs {
+		addFilter(`EXISTS (SELECT 1 FROM note_tags nt WHERE nt.note_id = n.id AND nt.tag_id = ANY($%d))`, group)
+	}
+
+	if len(opts.ExcludeTagIDs) > 0 {
+		addFilter(`NOT EXISTS (SELECT 1 FROM note_tags nt WHERE nt.note_id = n.id AND nt.tag_id = ANY($%d))`, opts.ExcludeTagIDs)
+	}
+
+	if opts.Untagged {
+		whereConditions = append(whereConditions, `NOT EXISTS (SELECT 1 FROM note_tags nt WHERE nt.note_id = n.id)`)
+	}
+
+	// Add date filters if provided
+	if opts.CreatedAfter != nil {
+		addFilter(`n.created_at >= $%d`, *opts.CreatedAfter)
+	}
+	if opts.CreatedBefore != nil {
+		addFilter(`n.created_at < $%d`, *opts.CreatedBefore)
+	}
+	if opts.UpdatedAfter != nil {
+		addFilter(`n.updated_at >= $%d`, *opts.UpdatedAfter)
+	}
+	if opts.UpdatedBefore != nil {
+		addFilter(`n.updated_at < $%d`, *opts.UpdatedBefore)
+	}
+
+	// Add source filters if provided
+	if opts.HasSource != nil {
+		if *opts.HasSource {
+			whereConditions = append(whereConditions, `COALESCE(n.source_url, '') <> ''`)
+		} else {
+			whereConditions = append(whereConditions, `COALESCE(n.source_url, '') = ''`)
+		}
+	}
+
+	if opts.SourceDomain != "" {
+		// Compare the host of the source URL (without scheme, credentials and port) with the domain and its subdomains
+		addFilter(`(
+			substring(lower(n.source_url) from '^[^:/]+://(?:[^@/]*@)?([^/:?#]+)') = $%[1]d
+			OR right(substring(lower(n.source_url) from '^[^:/]+://(?:[^@/]*@)?([^/:?#]+)'), length($%[1]d) + 1) = '.' || $%[1]d
+		)`, opts.SourceDomain)
+	}
+
+	if opts.IsPublic != nil {
+		addFilter(`n.is_public = $%d`, *opts.IsPublic)
+	}
+
+	// Add search filter if provided. The language is inlined rather than bound so that
+	// the default configuration still matches the idx_notes_search expression index.
+	language := opts.Language
+	if !slices.Contains(SearchLanguages, language) {
+		language = DefaultSearchLanguage
+	}
+
+	tsQuery := "" // Ranks the results and highlights snippets
+	if opts.Search != "" {
+		tsQuery = fmt.Sprintf(`plainto_tsquery('%s', $%d)`, language, argPos)
 		whereConditions = append(whereConditions, fmt.Sprintf(`
-			to_tsvector('english', n.title || ' ' || n.content_md) @@ plainto_tsquery('english', $%d)
-		`, argPos))
-		args = append(args, search)
+			to_tsvector('%s', n.title || ' ' || n.content_md) @@ %s
+		`, language, tsQuery))
+		args = append(args, opts.Search)
 		argPos++
 	}
 
+	// Add the compiled search query if provided; its words rank the results when there is no search
+	if opts.Query != nil {
+		condition, queryArgs := opts.Query.SQL(language, argPos)
+		whereConditions = append(whereConditions, condition)
+		args = append(args, queryArgs...)
+		argPos += len(queryArgs)
+
+		if rankText := opts.Query.RankText(); tsQuery == "" && rankText != "" {
+			tsQuery = fmt.Sprintf(`plainto_tsquery('%s', $%d)`, language, argPos)
+			args = append(args, rankText)
+			argPos++
+		}
+	}
+
+	rankExpr := ""
+	if tsQuery != "" {
+		rankExpr = fmt.Sprintf(`ts_rank(to_tsvector('%s', n.title || ' ' || n.content_md), %s)`, language, tsQuery)
+	}
+
 	whereClause := strings.Join(whereConditions, " AND ")
 
 	// Count total
@@ -186,16 +478,70 @@ func (r *PostgresNoteRepository) List(ctx context.Context, userID string, page,
 		return nil, 0, fmt.Errorf("failed to count notes: %w", err)
 	}
 
+	// Sort key; the note ID breaks ties so that every note has a unique position
+	var sortExpr string
+	switch opts.Sort {
+	case NoteSortUpdatedAt:
+		sortExpr = "n.updated_at"
+	case NoteSortTitle:
+		sortExpr = "n.title"
+	case NoteSortRelevance:
+		if rankExpr == "" {
+			return nil, 0, fmt.Errorf("sorting by relevance requires a search query")
+		}
+		sortExpr = rankExpr
+	default:
+		sortExpr = "n.created_at"
+	}
+
+	direction, comparison := "ASC", ">"
+	if opts.Desc {
+		direction, comparison = "DESC", "<"
+	}
+
+	// Keyset pagination: continue after the cursor instead of skipping rows
+	if opts.After != nil {
+		var value interface{}
+		switch opts.Sort {
+		case NoteSortUpdatedAt:
+			value = opts.After.UpdatedAt
+		case NoteSortTitle:
+			value = opts.After.Title
+		case NoteSortRelevance:
+			value = opts.After.Rank
+		default:
+			value = opts.After.CreatedAt
+		}
+
+		whereClause += fmt.Sprintf(" AND (%s, n.id) %s ($%d, $%d)", sortExpr, comparison, argPos, argPos+1)
+		args = append(args, value, opts.After.ID)
+		argPos += 2
+		opts.Offset = 0
+	}
+
+	selectRank := ""
+	if opts.Sort == NoteSortRelevance {
+		selectRank = ", " + rankExpr
+	}
+
+	// Matching excerpts of the content when searching
+	selectHeadline := ""
+	if tsQuery != "" {
+		selectHeadline = fmt.Sprintf(", ts_headline('%s', n.content_md, %s, $%d)", language, tsQuery, argPos)
+		args = append(args, headlineOptions)
+		argPos++
+	}
+
 	// Get notes
-	args = append(args, perPage, offset)
+	args = append(args, opts.Limit, opts.Offset)
 	query := fmt.Sprintf(`
 		SELECT id, user_id, title, content_md, source_url, is_public, public_slug, 
-		       view_count, shared_at, created_at, updated_at
+		       view_count, shared_at, created_at, updated_at%s%s
 		FROM notes n
 		WHERE %s
-		ORDER BY created_at DESC
+		ORDER BY %s %s, n.id %s
 		LIMIT $%d OFFSET $%d
-	`, whereClause, argPos, argPos+1)
+	`, selectRank, selectHeadline, whereClause, sortExpr, direction, direction, argPos, argPos+1)
 
 	rows, err := r.db.Query(ctx, query, args...)
 	if err != nil {
@@ -206,12 +552,22 @@ func (r *PostgresNoteRepository) List(ctx context.Context, userID string, page,
 	notes := []Note{}
 	for rows.Next() {
 		var note Note
-		err := rows.Scan(&note.ID, &note.UserID, &note.Title, &note.ContentMd, &note.SourceURL,
+		fields := []interface{}{&note.ID, &note.UserID, &note.Title, &note.ContentMd, &note.SourceURL,
 			&note.IsPublic, &note.PublicSlug, &note.ViewCount, &note.SharedAt,
-			&note.CreatedAt, &note.UpdatedAt)
-		if err != nil {
+			&note.CreatedAt, &note.UpdatedAt}
+		if opts.Sort == NoteSortRelevance {
+			fields = append(fields, &note.Rank)
+		}
+		var headline string
+		if selectHeadline != "" {
+			fields = append(fields, &headline)
+		}
+		if err := rows.Scan(fields...); err != nil {
 			return nil, 0, fmt.Errorf("failed to scan note: %w", err)
 		}
+		if selectHeadline != "" {
+			note.Snippets = parseHeadline(headline)
+		}
 		notes = append(notes, note)
 	}
 
@@ -247,7 +603,7 @@ func (r *PostgresNoteRepository) GetNoteTags(ctx context.Context, noteID string)
 
 func (r *PostgresNoteRepository) CountByUser(ctx context.Context, userID string) (int, error) {
 	var count int
-	query := `SELECT COUNT(*) FROM notes WHERE user_id = $1`
+	query := `SELECT COUNT(*) FROM notes WHERE user_id = $1 AND deleted_at IS NULL`
 	err := r.db.QueryRow(ctx, query, userID).Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("failed to count notes: %w", err)
@@ -265,14 +621,14 @@ func (r *PostgresNoteRepository) TogglePublic(ctx context.Context, userID, noteI
 		query = `
 			UPDATE notes 
 			SET is_public = $1, public_slug = $2, shared_at = $3, updated_at = $4
-			WHERE id = $5 AND user_id = $6
+			WHERE id = $5 AND user_id = $6 AND deleted_at IS NULL
 		`
 		args = []interface{}{isPublic, publicSlug, now, now, noteID, userID}
 	} else {
 		query = `
 			UPDATE notes 
 			SET is_public = $1, public_slug = NULL, shared_at = NULL, updated_at = $2
-			WHERE id = $3 AND user_id = $4
+			WHERE id = $3 AND user_id = $4 AND deleted_at IS NULL
 		`
 		args = []interface{}{isPublic, time.Now(), noteID, userID}
 	}
@@ -297,7 +653,7 @@ func (r *PostgresNoteRepository) FindByPublicSlug(ctx context.Context, slug stri
 		SELECT id, user_id, title, content_md, source_url, is_public, public_slug, 
 		       view_count, shared_at, created_at, updated_at
 		FROM notes
-		WHERE public_slug = $1 AND is_public = TRUE
+		WHERE public_slug = $1 AND is_public = TRUE AND deleted_at IS NULL
 	`
 
 	err := r.db.QueryRow(ctx, query, slug).Scan(
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math"
 	"regexp"
+	"slices"
 	"strings"
 	"time"
 
@@ -21,15 +22,17 @@ type NoteRepository interface {
 		userID, noteID string,
 		title, contentMd *string,
 		sourceURL *string,
+		tagIDs []string, // nil keeps the tags
+		expectedVersion int,
 	) (*Note, error)
+	Trash(ctx context.Context, userID, noteID string) error
+	TrashState(ctx context.Context, noteID string) (exists, trashed bool, err error)
+	Restore(ctx context.Context, userID, noteID string) (*Note, error)
 	Delete(ctx context.Context, userID, noteID string) error
-	List(
-		ctx context.Context,
-		userID string,
-		page, perPage int,
-		tagIDs []string,
-		search string,
-	) ([]Note, int, error)
+	EmptyTrash(ctx context.Context, userID string) ([]string, error)
+	PurgeTrashed(ctx context.Context, before time.Time) ([]string, error)
+	ListTrashed(ctx context.Context, userID string, page, perPage int) ([]Note, int, error)
+	List(ctx context.Context, userID string, opts NoteListOptions) ([]Note, int, error)
 	GetNoteTags(ctx context.Context, noteID string) ([]string, error)
 	CountByUser(ctx context.Context, userID string) (int, error)
 	// Public sharing methods
@@ -47,7 +50,7 @@ type NoteRepository interface {
 // TagRepository defines what the notes service needs from a tag repository
 // Interface is defined here by the consumer (Service), not by the implementation
 type TagRepository interface {
-	FindOrCreateByName(ctx context.Context, userID, name string) (*Tag, error)
+	FindOrCreateByName(ctx context.Context, userID, name string, parentID *string) (*Tag, error)
 	FindByID(ctx context.Context, userID, tagID string) (*Tag, error)
 	FindByIDs(ctx context.Context, userID string, tagIDs []string) ([]Tag, error)
 	FindByNames(ctx context.Context, userID string, names []string) ([]Tag, error)
@@ -55,8 +58,17 @@ type TagRepository interface {
 	RemoveFromNote(ctx context.Context, noteID, tagID string) error
 	SetNoteTags(ctx context.Context, noteID string, tagIDs []string) error
 	ListAll(ctx context.Context, userID string) ([]Tag, error)
+	ListTree(ctx context.Context, userID string) ([]*TagNode, error)
+	FindPrimaryTags(ctx context.Context, userID string) (map[string]Tag, error)
+	CoOccurrences(ctx context.Context, userID string) ([]TagPair, error)
+	WeeklyUsage(ctx context.Context, userID string, since time.Time) ([]TagUsage, error)
+	UpdateMetadata(ctx context.Context, tag *Tag) error
+	FindDescendantIDs(ctx context.Context, userID string, tagIDs []string) ([]string, error)
 	CountAll(ctx context.Context, userID string) (int, error)
-	Delete(ctx context.Context, userID, tagID string) ([]string, error) // Returns deleted note IDs
+	FindNotesByTag(ctx context.Context, userID, tagID string) ([]LinkedNote, error)
+	Rename(ctx context.Context, userID, tagID, name string, parentID *string) (*Tag, error)
+	Merge(ctx context.Context, userID, targetTagID string, sourceTagIDs []string) ([]string, error) // Returns updated note IDs
+	Delete(ctx context.Context, userID, tagID, mode, targetTagID string) ([]string, error)          // Returns affected note IDs
 	GetNotesCountByTag(ctx context.Context, userID, tagID string) (int, error)
 }
 
@@ -68,7 +80,8 @@ type VectorStore interface {
 		vector []float32,
 		payload map[string]interface{},
 	) error
-	DeletePoint(ctx context.Context, id string) error
+	DeleteNoteChunks(ctx context.Context, noteID string, from int) error // Deletes chunks from index from on
+	SetNoteTrashed(ctx context.Context, noteID string, trashed bool) error
 	SearchWithFilter(
 		ctx context.Context,
 		vector []float32,
@@ -98,15 +111,58 @@ type LinkRepository interface {
 	FindNoteByTitle(ctx context.Context, userID, title string) (string, error)
 }
 
+// PreferenceProvider defines what the notes service needs to read user preferences
+type PreferenceProvider interface {
+	NotePreferences(ctx context.Context, userID string) (*Preferences, error)
+}
+
+// DefaultSearchLanguage is the text search configuration of the idx_notes_search index
+const DefaultSearchLanguage = "english"
+
+// SearchLanguages lists the Postgres text search configurations users may choose
+var SearchLanguages = []string{
+	"simple", "arabic", "danish", "dutch", "english", "finnish", "french", "german",
+	"greek", "hungarian", "indonesian", "irish", "italian", "lithuanian", "nepali",
+	"norwegian", "portuguese", "romanian", "russian", "spanish", "swedish", "tamil", "turkish",
+}
+
+// Tag metadata limits
+const (
+	maxTagIconLength        = 64
+	maxTagDescriptionLength = 500
+)
+
+var tagColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// Tag analytics settings
+const (
+	defaultTagUsageWeeks      = 12
+	maxTagUsageWeeks          = 104
+	maxTagPairs               = 50
+	maxTagMergeSuggestions    = 20
+	mergeNameSimilarity       = 0.8 // Minimum name similarity to suggest a merge
+	mergeCoOccurrenceRatio    = 0.8 // Share of the smaller tag's notes that also carry the other tag
+	mergeCoOccurrenceMinNotes = 3
+)
+
+// hybridCandidateFactor is how many more results than requested each hybrid search retrieves
+const hybridCandidateFactor = 3
+
+// semanticChunkFactor is how many chunks vector search retrieves per requested note
+const semanticChunkFactor = 4
+
 type Service struct {
-	noteRepo         NoteRepository
-	tagRepo          TagRepository
-	linkRepo         LinkRepository
-	versionRepo      VersionRepository
-	vectorStore      VectorStore
-	embeddingService EmbeddingService
-	defaultPageSize  int
-	maxPageSize      int
+	noteRepo           NoteRepository
+	tagRepo            TagRepository
+	linkRepo           LinkRepository
+	versionRepo        VersionRepository
+	vectorStore        VectorStore
+	embeddingService   EmbeddingService
+	preferences        PreferenceProvider
+	defaultPageSize    int
+	maxPageSize        int
+	trashRetention     time.Duration
+	hybridSearchConfig HybridSearchConfig
 }
 
 func NewService(
@@ -116,17 +172,23 @@ func NewService(
 	versionRepo VersionRepository,
 	vectorStore VectorStore,
 	embeddingService EmbeddingService,
+	preferences PreferenceProvider,
 	defaultPageSize, maxPageSize int,
+	trashRetention time.Duration,
+	hybridSearchConfig HybridSearchConfig,
 ) *Service {
 	return &Service{
-		noteRepo:         noteRepo,
-		tagRepo:          tagRepo,
-		linkRepo:         linkRepo,
-		versionRepo:      versionRepo,
-		vectorStore:      vectorStore,
-		embeddingService: embeddingService,
-		defaultPageSize:  defaultPageSize,
-		maxPageSize:      maxPageSize,
+		noteRepo:           noteRepo,
+		tagRepo:            tagRepo,
+		linkRepo:           linkRepo,
+		versionRepo:        versionRepo,
+		vectorStore:        vectorStore,
+		embeddingService:   embeddingService,
+		preferences:        preferences,
+		defaultPageSize:    defaultPageSize,
+		maxPageSize:        maxPageSize,
+		trashRetention:     trashRetention,
+		hybridSearchConfig: hybridSearchConfig,
 	}
 }
 
@@ -151,6 +213,7 @@ func (s *Service) CreateNote(
 	if err != nil {
 		return nil, fmt.Errorf("failed to create note: %w", err)
 	}
+	note.Version = 1 // Created by the note_insert_version trigger
 
 	// Handle tags if provided
 	if len(req.Tags) > 0 {
@@ -172,6 +235,28 @@ func (s *Service) CreateNote(
 		fmt.Printf("Warning: failed to process note links: %v\n", err)
 	}
 
+	// Share the note right away if requested or if the user prefers public notes
+	isPublic := s.userPreferences(ctx, userID).PublicByDefault
+	if req.IsPublic != nil {
+		isPublic = *req.IsPublic
+	}
+
+	if isPublic {
+		slug, err := s.availableSlug(ctx, note)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := s.noteRepo.TogglePublic(ctx, userID, note.ID, true, &slug); err != nil {
+			return nil, fmt.Errorf("failed to share note: %w", err)
+		}
+
+		sharedAt := time.Now()
+		note.IsPublic = true
+		note.PublicSlug = &slug
+		note.SharedAt = &sharedAt
+	}
+
 	// Generate embedding and store in Qdrant (async, don't fail note creation if this fails)
 	go func() {
 		if err := s.indexNote(context.Background(), note); err != nil {
@@ -197,25 +282,52 @@ func uniqueStrings(input []string) []string {
 	return result
 }
 
-// indexNote generates embedding and stores it in Qdrant
+// indexNote splits the note into chunks and stores one embedding per chunk in Qdrant
 func (s *Service) indexNote(ctx context.Context, note *Note) error {
-	// Generate embedding
-	vector, err := s.embeddingService.GenerateForNote(note.Title, note.ContentMd)
-	if err != nil {
-		return fmt.Errorf("failed to generate embedding: %w", err)
+	chunks := chunkNote(note.ContentMd)
+
+	for _, chunk := range chunks {
+		// Generate embedding; the title gives every chunk the note's context
+		vector, err := s.embeddingService.GenerateForNote(note.Title, chunk.Text)
+		if err != nil {
+			return fmt.Errorf("failed to generate embedding: %w", err)
+		}
+
+		// Prepare payload with note metadata and where the chunk is in the note
+		payload := map[string]interface{}{
+			"note_id":     note.ID,
+			"user_id":     note.UserID,
+			"title":       note.Title,
+			"chunk_index": chunk.Index,
+			"start":       chunk.Start,
+			"end":         chunk.End,
+			"content":     chunk.Text,
+		}
+
+		// Store in vector database
+		pointID := fmt.Sprintf("%s:%d", note.ID, chunk.Index)
+		if err := s.vectorStore.UpsertPoint(ctx, pointID, vector, payload); err != nil {
+			return fmt.Errorf("failed to store vector: %w", err)
+		}
 	}
 
-	// Prepare payload with note metadata (including content for GPT context)
-	payload := map[string]interface{}{
-		"note_id": note.ID,
-		"user_id": note.UserID,
-		"title":   note.Title,
-		"content": note.ContentMd, // Store full content for GPT context
+	// Remove chunks left over from a longer version of the note
+	if err := s.vectorStore.DeleteNoteChunks(ctx, note.ID, len(chunks)); err != nil {
+		return fmt.Errorf("failed to delete stale chunks: %w", err)
 	}
 
-	// Store in vector database
-	if err := s.vectorStore.UpsertPoint(ctx, note.ID, vector, payload); err != nil {
-		return fmt.Errorf("failed to store vector: %w", err)
+	// The upserts replaced the payload, so the note may have been trashed or deleted
+	// while it was being indexed. Checking afterwards is enough: a later trash or delete
+	// updates the points written above itself.
+	exists, trashed, err := s.noteRepo.TrashState(ctx, note.ID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return s.vectorStore.DeleteNoteChunks(ctx, note.ID, 0)
+	}
+	if trashed {
+		return s.vectorStore.SetNoteTrashed(ctx, note.ID, true)
 	}
 
 	return nil
@@ -234,31 +346,45 @@ func (s *Service) GetNote(ctx context.Context, userID, noteID string) (*Note, er
 	}
 
 	note.Tags = tags
+
+	// The version number is the note's ETag
+	note.Version, err = s.versionRepo.GetLatestVersionNumber(ctx, noteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get note version: %w", err)
+	}
+
 	return note, nil
 }
 
+// UpdateNote updates a note. A non-zero baseVersion makes the update conditional: if the note
+// has a newer version, a *ConflictError with the current note and a diff is returned.
 func (s *Service) UpdateNote(
 	ctx context.Context,
 	userID, noteID string,
 	req UpdateNoteRequest,
+	baseVersion int,
 ) (*Note, error) {
-	// Update note fields
-	note, err := s.noteRepo.Update(ctx, userID, noteID, req.Title, req.ContentMd, req.SourceURL)
-	if err != nil {
-		return nil, err
-	}
-
-	// Update tags if provided
+	// Resolve tags if provided; they are replaced together with the note fields
+	var tagIDs []string
 	if req.Tags != nil {
-		tagIDs, err := s.ensureTagsExist(ctx, userID, req.Tags)
+		req.Tags = uniqueStrings(req.Tags)
+		ids, err := s.ensureTagsExist(ctx, userID, req.Tags)
 		if err != nil {
 			return nil, fmt.Errorf("failed to process tags: %w", err)
 		}
+		tagIDs = append([]string{}, ids...)
+	}
 
-		if err := s.tagRepo.SetNoteTags(ctx, note.ID, tagIDs); err != nil {
-			return nil, fmt.Errorf("failed to update tags: %w", err)
-		}
+	// Update note fields and tags
+	note, err := s.noteRepo.Update(ctx, userID, noteID, req.Title, req.ContentMd, req.SourceURL, tagIDs, baseVersion)
+	if conflict, ok := err.(*ConflictError); ok {
+		return nil, s.describeConflict(ctx, userID, noteID, conflict)
+	}
+	if err != nil {
+		return nil, err
+	}
 
+	if req.Tags != nil {
 		note.Tags = req.Tags
 	} else {
 		// Get existing tags
@@ -283,21 +409,45 @@ func (s *Service) UpdateNote(
 		}
 	}()
 
+	note.Version, err = s.versionRepo.GetLatestVersionNumber(ctx, noteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get note version: %w", err)
+	}
+
 	return note, nil
 }
 
+// describeConflict adds the current note and the changes since the client's base version
+func (s *Service) describeConflict(ctx context.Context, userID, noteID string, conflict *ConflictError) error {
+	current, err := s.GetNote(ctx, userID, noteID)
+	if err != nil {
+		return err
+	}
+	conflict.Current = current
+
+	baseVersion, err := s.versionRepo.GetVersionByNumber(ctx, noteID, conflict.BaseVersion)
+	if err != nil {
+		// The client sent a version that never existed; there is nothing to diff against
+		return conflict
+	}
+
+	currentVersion, err := s.versionRepo.GetVersionByNumber(ctx, noteID, conflict.CurrentVersion)
+	if err != nil {
+		return conflict
+	}
+
+	conflict.Diff = diffVersions(baseVersion, currentVersion)
+	return conflict
+}
+
+// DeleteNote moves a note to the trash; it can be restored until it is purged
 func (s *Service) DeleteNote(ctx context.Context, userID, noteID string) error {
-	// Delete from database
-	if err := s.noteRepo.Delete(ctx, userID, noteID); err != nil {
+	if err := s.noteRepo.Trash(ctx, userID, noteID); err != nil {
 		return err
 	}
 
-	// Delete from vector store (async, don't fail if this fails)
-	go func() {
-		if err := s.vectorStore.DeletePoint(context.Background(), noteID); err != nil {
-			fmt.Printf("Warning: failed to delete note %s from vector store: %v\n", noteID, err)
-		}
-	}()
+	// Hide from search (async, don't fail if this fails)
+	go s.setTrashed([]string{noteID}, true)
 
 	return nil
 }
@@ -307,98 +457,633 @@ func (s *Service) ListNotes(
 	userID string,
 	req ListNotesRequest,
 ) (*ListNotesResponse, error) {
+	prefs := s.userPreferences(ctx, userID)
+
 	// Validate pagination
 	if req.Page < 1 {
 		req.Page = 1
 	}
 
 	if req.PerPage < 1 {
-		req.PerPage = s.defaultPageSize
+		req.PerPage = prefs.PageSize
 	}
 
 	if req.PerPage > s.maxPageSize {
 		req.PerPage = s.maxPageSize
 	}
 
-	// Get tag IDs if tag filter is provided
-	var tagIDs []string
-	if len(req.Tags) > 0 {
-		tags, err := s.tagRepo.FindByNames(ctx, userID, req.Tags)
-		if err != nil {
-			return nil, fmt.Errorf("failed to find tags: %w", err)
+	opts := NoteListOptions{
+		Search:   req.Search,
+		Language: prefs.SearchLanguage,
+		Sort:     req.Sort,
+		Offset:   (req.Page - 1) * req.PerPage,
+		Limit:    req.PerPage + 1, // One extra row tells whether there is a next page
+	}
+
+	switch req.Sort {
+	case "":
+		opts.Sort = NoteSortCreatedAt
+	case NoteSortCreatedAt, NoteSortUpdatedAt, NoteSortTitle, NoteSortRelevance:
+	default:
+		return nil, &ListRequestError{Message: "invalid sort: must be created_at, updated_at, title or relevance"}
+	}
+
+	switch req.Order {
+	case "":
+		opts.Desc = opts.Sort != NoteSortTitle
+	case "asc":
+		opts.Desc = false
+	case "desc":
+		opts.Desc = true
+	default:
+		return nil, &ListRequestError{Message: "invalid order: must be asc or desc"}
+	}
+
+	if req.Cursor != "" {
+		cursor, err := DecodeCursor(req.Cursor)
+		if err != nil {
+			return nil, &ListRequestError{Message: err.Error()}
+		}
+		if cursor.Sort != opts.Sort || cursor.Desc != opts.Desc {
+			return nil, &ListRequestError{Message: "cursor does not match sort and order"}
+		}
+		opts.After = cursor
+	}
+
+	if err := s.applyNoteFilters(ctx, userID, req, &opts); err != nil {
+		if err == errNoMatchingTags {
+			return &ListNotesResponse{Notes: []Note{}, Page: req.Page, PerPage: req.PerPage}, nil
+		}
+		return nil, err
+	}
+
+	if opts.Sort == NoteSortRelevance && opts.Search == "" && (opts.Query == nil || opts.Query.RankText() == "") {
+		return nil, &ListRequestError{Message: "sort=relevance requires search or words in q"}
+	}
+
+	// Get notes
+	notes, total, err := s.noteRepo.List(ctx, userID, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	nextCursor := ""
+	if len(notes) > req.PerPage {
+		notes = notes[:req.PerPage]
+		nextCursor = EncodeCursor(cursorAfter(notes[len(notes)-1], opts.Sort, opts.Desc))
+	}
+
+	// Get tags for each note
+	for i := range notes {
+		tags, err := s.noteRepo.GetNoteTags(ctx, notes[i].ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get note tags: %w", err)
+		}
+		notes[i].Tags = tags
+	}
+
+	totalPages := int(math.Ceil(float64(total) / float64(req.PerPage)))
+
+	return &ListNotesResponse{
+		Notes:      notes,
+		Total:      total,
+		Page:       req.Page,
+		PerPage:    req.PerPage,
+		TotalPages: totalPages,
+		NextCursor: nextCursor,
+	}, nil
+}
+
+// errNoMatchingTags means a tag filter names only tags the user does not have
+var errNoMatchingTags = fmt.Errorf("no matching tags")
+
+// applyNoteFilters validates the filters of req and copies them into opts
+func (s *Service) applyNoteFilters(ctx context.Context, userID string, req ListNotesRequest, opts *NoteListOptions) error {
+	// Any of: unknown tags are ignored, but at least one tag must exist
+	anyTags, _, err := s.resolveTagFilter(ctx, userID, req.Tags, req.IncludeDescendants)
+	if err != nil {
+		return err
+	}
+	if len(req.Tags) > 0 && len(anyTags) == 0 {
+		return errNoMatchingTags
+	}
+	for _, group := range anyTags {
+		opts.TagIDs = append(opts.TagIDs, group...)
+	}
+
+	// All of: every tag must exist
+	allTags, missing, err := s.resolveTagFilter(ctx, userID, req.AllTags, req.IncludeDescendants)
+	if err != nil {
+		return err
+	}
+	if missing {
+		return errNoMatchingTags
+	}
+	opts.AllTagIDs = allTags
+
+	excludeTags, _, err := s.resolveTagFilter(ctx, userID, req.ExcludeTags, req.IncludeDescendants)
+	if err != nil {
+		return err
+	}
+	for _, group := range excludeTags {
+		opts.ExcludeTagIDs = append(opts.ExcludeTagIDs, group...)
+	}
+
+	opts.Untagged = req.Untagged
+
+	dates := []struct {
+		name  string
+		value string
+		dest  **time.Time
+	}{
+		{"created_after", req.CreatedAfter, &opts.CreatedAfter},
+		{"created_before", req.CreatedBefore, &opts.CreatedBefore},
+		{"updated_after", req.UpdatedAfter, &opts.UpdatedAfter},
+		{"updated_before", req.UpdatedBefore, &opts.UpdatedBefore},
+	}
+	for _, date := range dates {
+		if date.value == "" {
+			continue
+		}
+		parsed, err := parseDateFilter(date.value)
+		if err != nil {
+			return &ListRequestError{Message: fmt.Sprintf("invalid %s: use RFC 3339 or YYYY-MM-DD", date.name)}
+		}
+		*date.dest = &parsed
+	}
+
+	opts.HasSource = req.HasSource
+	opts.SourceDomain = strings.ToLower(strings.TrimSpace(req.SourceDomain))
+	opts.IsPublic = req.IsPublic
+
+	query, err := ParseQuery(req.Query)
+	if err != nil {
+		return err
+	}
+	opts.Query = query
+
+	return nil
+}
+
+// resolveTagFilter looks up tags by name and returns one group of tag IDs per tag found,
+// holding the tag and, if requested, its descendants. missing reports unknown names.
+func (s *Service) resolveTagFilter(
+	ctx context.Context,
+	userID string,
+	names []string,
+	includeDescendants bool,
+) (groups [][]string, missing bool, err error) {
+	if len(names) == 0 {
+		return nil, false, nil
+	}
+
+	normalized := make([]string, 0, len(names))
+	for _, name := range names {
+		normalized = append(normalized, normalizeTagPath(name))
+	}
+	normalized = uniqueStrings(normalized)
+
+	tags, err := s.tagRepo.FindByNames(ctx, userID, normalized)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to find tags: %w", err)
+	}
+
+	for _, tag := range tags {
+		group := []string{tag.ID}
+		if includeDescendants {
+			group, err = s.tagRepo.FindDescendantIDs(ctx, userID, group)
+			if err != nil {
+				return nil, false, err
+			}
+		}
+		groups = append(groups, group)
+	}
+
+	return groups, len(tags) < len(normalized), nil
+}
+
+// parseDateFilter accepts an RFC 3339 timestamp or a YYYY-MM-DD date (midnight UTC)
+func parseDateFilter(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
+
+// ListAllTags returns the user's tags both as a flat list and as a tree with note counts
+func (s *Service) ListAllTags(ctx context.Context, userID string) (*ListTagsResponse, error) {
+	nodes, err := s.tagRepo.ListTree(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]*TagNode, len(nodes))
+	for _, node := range nodes {
+		byID[node.ID] = node
+	}
+
+	response := &ListTagsResponse{
+		Tags: make([]Tag, 0, len(nodes)),
+		Tree: []*TagNode{},
+	}
+
+	// Nodes are sorted by name, so children end up sorted too
+	for _, node := range nodes {
+		response.Tags = append(response.Tags, node.Tag)
+
+		if node.ParentID != nil {
+			if parent, ok := byID[*node.ParentID]; ok {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		response.Tree = append(response.Tree, node)
+	}
+
+	return response, nil
+}
+
+// UpdateTag applies the metadata changes in req and renames the tag if a new name is given
+func (s *Service) UpdateTag(ctx context.Context, userID, tagID string, req UpdateTagRequest) (*Tag, error) {
+	tag, err := s.tagRepo.FindByID(ctx, userID, tagID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Color != nil || req.Icon != nil || req.Description != nil || req.Pinned != nil {
+		if req.Color != nil && *req.Color != "" && !tagColorPattern.MatchString(*req.Color) {
+			return nil, fmt.Errorf("color must be a hex colour such as #3b82f6")
+		}
+		if req.Icon != nil && len(*req.Icon) > maxTagIconLength {
+			return nil, fmt.Errorf("icon must be at most %d characters", maxTagIconLength)
+		}
+		if req.Description != nil && len(*req.Description) > maxTagDescriptionLength {
+			return nil, fmt.Errorf("description must be at most %d characters", maxTagDescriptionLength)
+		}
+
+		if req.Color != nil {
+			tag.Color = emptyToNil(strings.ToLower(*req.Color))
+		}
+		if req.Icon != nil {
+			tag.Icon = emptyToNil(strings.TrimSpace(*req.Icon))
+		}
+		if req.Description != nil {
+			tag.Description = emptyToNil(strings.TrimSpace(*req.Description))
+		}
+		if req.Pinned != nil {
+			tag.Pinned = *req.Pinned
+		}
+
+		if err := s.tagRepo.UpdateMetadata(ctx, tag); err != nil {
+			return nil, err
+		}
+	}
+
+	if req.Name == nil {
+		return tag, nil
+	}
+
+	return s.renameTag(ctx, tag, *req.Name)
+}
+
+// renameTag renames a tag; every note carrying it gets a new version with the new tag name
+func (s *Service) renameTag(ctx context.Context, tag *Tag, newName string) (*Tag, error) {
+	userID := tag.UserID
+
+	name := normalizeTagPath(newName)
+	if name == "" {
+		return nil, fmt.Errorf("tag name cannot be empty")
+	}
+
+	if tag.Name == name {
+		return tag, nil
+	}
+
+	if strings.HasPrefix(name, tag.Name+"/") {
+		return nil, fmt.Errorf("a tag cannot be moved under itself")
+	}
+
+	existing, err := s.tagRepo.FindByNames(ctx, userID, []string{name})
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) > 0 {
+		return nil, fmt.Errorf("tag %q already exists", name)
+	}
+
+	// A slash in the new name moves the tag under that parent
+	var parentID *string
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		parent, err := s.ensureTagPath(ctx, userID, name[:i])
+		if err != nil {
+			return nil, err
+		}
+		parentID = &parent.ID
+	}
+
+	return s.tagRepo.Rename(ctx, userID, tag.ID, name, parentID)
+}
+
+// primaryTagGroups returns the primary tag of each note and a graph group per tag.
+// Groups are numbered from 1 in tag name order; untagged notes stay in group 0.
+func (s *Service) primaryTagGroups(ctx context.Context, userID string) (map[string]Tag, map[string]int, error) {
+	primaryTags, err := s.tagRepo.FindPrimaryTags(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	names := make(map[string]string)
+	for _, tag := range primaryTags {
+		names[tag.ID] = tag.Name
+	}
+
+	tagIDs := make([]string, 0, len(names))
+	for id := range names {
+		tagIDs = append(tagIDs, id)
+	}
+	slices.SortFunc(tagIDs, func(a, b string) int {
+		return strings.Compare(names[a], names[b])
+	})
+
+	groups := make(map[string]int, len(tagIDs))
+	for i, id := range tagIDs {
+		groups[id] = i + 1
+	}
+
+	return primaryTags, groups, nil
+}
+
+// emptyToNil turns an empty string into nil so that the column is cleared
+func emptyToNil(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+// MergeTags folds the source tags into the target tag and deletes them
+func (s *Service) MergeTags(ctx context.Context, userID string, req MergeTagsRequest) (*MergeTagsResponse, error) {
+	if req.TargetTagID == "" {
+		return nil, fmt.Errorf("target_tag_id is required")
+	}
+
+	sourceTagIDs := uniqueStrings(req.SourceTagIDs)
+	if len(sourceTagIDs) == 0 {
+		return nil, fmt.Errorf("source_tag_ids is required")
+	}
+
+	if slices.Contains(sourceTagIDs, req.TargetTagID) {
+		return nil, fmt.Errorf("target tag cannot be one of the source tags")
+	}
+
+	target, err := s.tagRepo.FindByID(ctx, userID, req.TargetTagID)
+	if err != nil {
+		return nil, fmt.Errorf("target %w", err)
+	}
+
+	sources, err := s.tagRepo.FindByIDs(ctx, userID, sourceTagIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sources) != len(sourceTagIDs) {
+		return nil, fmt.Errorf("one or more source tags not found")
+	}
+
+	noteIDs, err := s.tagRepo.Merge(ctx, userID, target.ID, sourceTagIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge tags: %w", err)
+	}
+
+	return &MergeTagsResponse{
+		Tag:          *target,
+		MergedTags:   sources,
+		NotesUpdated: len(noteIDs),
+	}, nil
+}
+
+// DeleteTag deletes a tag and handles its notes according to the mode.
+// With DryRun set it only returns the notes that would be affected.
+func (s *Service) DeleteTag(
+	ctx context.Context,
+	userID, tagID string,
+	req DeleteTagRequest,
+) (*DeleteTagResponse, error) {
+	if req.Mode == "" {
+		req.Mode = TagDeleteDetach
+	}
+
+	// First verify the tag exists and belongs to the user
+	tag, err := s.tagRepo.FindByID(ctx, userID, tagID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &DeleteTagResponse{
+		Tag:    *tag,
+		Mode:   req.Mode,
+		DryRun: req.DryRun,
+	}
+
+	switch req.Mode {
+	case TagDeleteDetach, TagDeleteCascade:
+		req.TargetTagID = ""
+	case TagDeleteReassign:
+		if req.TargetTagID == "" {
+			return nil, fmt.Errorf("target_tag_id is required for reassign")
+		}
+		if req.TargetTagID == tagID {
+			return nil, fmt.Errorf("cannot reassign notes to the tag being deleted")
+		}
+
+		targetTag, err := s.tagRepo.FindByID(ctx, userID, req.TargetTagID)
+		if err != nil {
+			return nil, fmt.Errorf("target %w", err)
+		}
+		response.TargetTag = targetTag
+	default:
+		return nil, fmt.Errorf("invalid mode: must be detach, cascade or reassign")
+	}
+
+	// Preview the notes that carry the tag
+	response.AffectedNotes, err = s.tagRepo.FindNotesByTag(ctx, userID, tagID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.DryRun {
+		return response, nil
+	}
+
+	noteIDs, err := s.tagRepo.Delete(ctx, userID, tagID, req.Mode, req.TargetTagID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete tag: %w", err)
+	}
+
+	// Hide trashed notes from search (async, don't fail if this fails)
+	if req.Mode == TagDeleteCascade && len(noteIDs) > 0 {
+		go s.setTrashed(noteIDs, true)
+	}
+
+	return response, nil
+}
+
+// GetTagAnalytics computes tag co-occurrence, weekly usage, orphaned tags and merge suggestions
+func (s *Service) GetTagAnalytics(ctx context.Context, userID string, weeks int) (*TagAnalyticsResponse, error) {
+	if weeks < 1 {
+		weeks = defaultTagUsageWeeks
+	}
+	if weeks > maxTagUsageWeeks {
+		weeks = maxTagUsageWeeks
+	}
+
+	totalNotes, err := s.noteRepo.CountByUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count notes: %w", err)
+	}
+
+	nodes, err := s.tagRepo.ListTree(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make(map[string]*TagNode, len(nodes))
+	orphaned := []Tag{}
+	for _, node := range nodes {
+		tags[node.ID] = node
+		if node.TotalNoteCount == 0 {
+			orphaned = append(orphaned, node.Tag)
+		}
+	}
+
+	pairs, err := s.tagRepo.CoOccurrences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Lift = P(A and B) / (P(A) * P(B))
+	for i := range pairs {
+		a, b := tags[pairs[i].TagAID], tags[pairs[i].TagBID]
+		if a == nil || b == nil {
+			continue
 		}
-
-		tagIDs = make([]string, len(tags))
-		for i, tag := range tags {
-			tagIDs[i] = tag.ID
+		pairs[i].TagA = a.Name
+		pairs[i].TagB = b.Name
+		if a.NoteCount > 0 && b.NoteCount > 0 {
+			pairs[i].Lift = float64(pairs[i].Count) * float64(totalNotes) / float64(a.NoteCount*b.NoteCount)
 		}
 	}
 
-	// Get notes
-	notes, total, err := s.noteRepo.List(ctx, userID, req.Page, req.PerPage, tagIDs, req.Search)
+	// Weekly usage, with empty weeks filled in
+	now := time.Now().UTC()
+	currentWeek := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).
+		AddDate(0, 0, -((int(now.Weekday()) + 6) % 7))
+	since := currentWeek.AddDate(0, 0, -7*(weeks-1))
+
+	usage, err := s.tagRepo.WeeklyUsage(ctx, userID, since)
 	if err != nil {
 		return nil, err
 	}
 
-	// Get tags for each note
-	for i := range notes {
-		tags, err := s.noteRepo.GetNoteTags(ctx, notes[i].ID)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get note tags: %w", err)
+	for i := range usage {
+		counts := make(map[string]int, len(usage[i].Weeks))
+		for _, week := range usage[i].Weeks {
+			counts[week.Week.Format(time.DateOnly)] = week.Count
+		}
+
+		usage[i].Weeks = make([]WeekCount, weeks)
+		for w := range weeks {
+			week := since.AddDate(0, 0, 7*w)
+			usage[i].Weeks[w] = WeekCount{Week: week, Count: counts[week.Format(time.DateOnly)]}
+		}
+
+		if tag := tags[usage[i].TagID]; tag != nil {
+			usage[i].Tag = tag.Name
 		}
-		notes[i].Tags = tags
 	}
 
-	totalPages := int(math.Ceil(float64(total) / float64(req.PerPage)))
+	slices.SortFunc(usage, func(a, b TagUsage) int {
+		return strings.Compare(a.Tag, b.Tag)
+	})
 
-	return &ListNotesResponse{
-		Notes:      notes,
-		Total:      total,
-		Page:       req.Page,
-		PerPage:    req.PerPage,
-		TotalPages: totalPages,
+	suggestions := tagMergeSuggestions(nodes, pairs)
+
+	if len(pairs) > maxTagPairs {
+		pairs = pairs[:maxTagPairs]
+	}
+
+	return &TagAnalyticsResponse{
+		TotalNotes:       totalNotes,
+		CoOccurrences:    pairs,
+		Usage:            usage,
+		OrphanedTags:     orphaned,
+		MergeSuggestions: suggestions,
 	}, nil
 }
 
-func (s *Service) ListAllTags(ctx context.Context, userID string) ([]Tag, error) {
-	return s.tagRepo.ListAll(ctx, userID)
-}
+// tagMergeSuggestions pairs tags with similar names or that are nearly always used together.
+// The tag with fewer notes is suggested as the source of the merge.
+func tagMergeSuggestions(nodes []*TagNode, pairs []TagPair) []TagMergeSuggestion {
+	coOccurrence := make(map[[2]string]int, len(pairs))
+	for _, pair := range pairs {
+		coOccurrence[[2]string{pair.TagAID, pair.TagBID}] = pair.Count
+		coOccurrence[[2]string{pair.TagBID, pair.TagAID}] = pair.Count
+	}
+
+	suggestions := []TagMergeSuggestion{}
+	for i, a := range nodes {
+		for _, b := range nodes[i+1:] {
+			// A tag and its ancestor are not duplicates
+			if strings.HasPrefix(a.Name, b.Name+"/") || strings.HasPrefix(b.Name, a.Name+"/") {
+				continue
+			}
 
-func (s *Service) DeleteTag(ctx context.Context, userID, tagID string) (int, error) {
-	// First verify the tag exists and belongs to the user
-	_, err := s.tagRepo.FindByID(ctx, userID, tagID)
-	if err != nil {
-		return 0, err
-	}
+			source, target := a, b
+			if source.TotalNoteCount > target.TotalNoteCount {
+				source, target = target, source
+			}
 
-	// Get count of notes that will be deleted
-	notesCount, err := s.tagRepo.GetNotesCountByTag(ctx, userID, tagID)
-	if err != nil {
-		return 0, fmt.Errorf("failed to count notes: %w", err)
-	}
+			similarity := TagNameSimilarity(a.Name, b.Name)
+			together := coOccurrence[[2]string{a.ID, b.ID}]
+
+			var reason string
+			switch {
+			case similarity >= mergeNameSimilarity:
+				reason = "similar names"
+			case together >= mergeCoOccurrenceMinNotes &&
+				float64(together) >= mergeCoOccurrenceRatio*float64(source.NoteCount):
+				reason = fmt.Sprintf("used together on %d of %d notes", together, source.NoteCount)
+			default:
+				continue
+			}
 
-	// Delete the tag (and associated notes), returns deleted note IDs
-	deletedNoteIDs, err := s.tagRepo.Delete(ctx, userID, tagID)
-	if err != nil {
-		return 0, fmt.Errorf("failed to delete tag: %w", err)
+			suggestions = append(suggestions, TagMergeSuggestion{
+				SourceTagID:  source.ID,
+				SourceTag:    source.Name,
+				TargetTagID:  target.ID,
+				TargetTag:    target.Name,
+				Similarity:   similarity,
+				CoOccurrence: together,
+				Reason:       reason,
+			})
+		}
 	}
 
-	// Delete notes from Qdrant (async, don't fail if this fails)
-	if len(deletedNoteIDs) > 0 {
-		go func() {
-			for _, noteID := range deletedNoteIDs {
-				if err := s.vectorStore.DeletePoint(context.Background(), noteID); err != nil {
-					fmt.Printf(
-						"Warning: failed to delete note %s from vector store: %v\n",
-						noteID,
-						err,
-					)
-				}
+	slices.SortStableFunc(suggestions, func(x, y TagMergeSuggestion) int {
+		if x.Similarity != y.Similarity {
+			if x.Similarity > y.Similarity {
+				return -1
 			}
-		}()
+			return 1
+		}
+		return y.CoOccurrence - x.CoOccurrence
+	})
+
+	if len(suggestions) > maxTagMergeSuggestions {
+		suggestions = suggestions[:maxTagMergeSuggestions]
 	}
 
-	return notesCount, nil
+	return suggestions
 }
 
 func (s *Service) GetStats(ctx context.Context, userID string) (*StatsResponse, error) {
@@ -445,51 +1130,146 @@ func (s *Service) Search(
 		req.Limit = 50
 	}
 
+	if req.Mode == "" {
+		req.Mode = SearchModeSemantic
+	}
+
+	var searchResults []SearchResult
+	var err error
+
+	switch req.Mode {
+	case SearchModeSemantic:
+		searchResults, err = s.semanticSearch(ctx, userID, req.Query, req.Limit)
+	case SearchModeHybrid:
+		searchResults, err = s.hybridSearch(ctx, userID, req.Query, req.Limit)
+	default:
+		return nil, fmt.Errorf("invalid mode: must be semantic or hybrid")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &SearchResponse{
+		Results: searchResults,
+		Query:   req.Query,
+		Mode:    req.Mode,
+	}, nil
+}
+
+// hybridSearch runs full-text and vector search and fuses both rankings.
+// Each search returns more candidates than requested so that notes found by only one still compete.
+func (s *Service) hybridSearch(ctx context.Context, userID, query string, limit int) ([]SearchResult, error) {
+	candidates := limit * hybridCandidateFactor
+
+	lexical, err := s.lexicalSearch(ctx, userID, query, candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	semantic, err := s.semanticSearch(ctx, userID, query, candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	return fuseRankings(lexical, semantic, s.hybridSearchConfig, limit), nil
+}
+
+// lexicalSearch ranks notes by full-text relevance, best first
+func (s *Service) lexicalSearch(ctx context.Context, userID, query string, limit int) ([]SearchResult, error) {
+	notes, _, err := s.noteRepo.List(ctx, userID, NoteListOptions{
+		Search:   query,
+		Language: s.userPreferences(ctx, userID).SearchLanguage,
+		Sort:     NoteSortRelevance,
+		Desc:     true,
+		Limit:    limit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search: %w", err)
+	}
+
+	results := make([]SearchResult, 0, len(notes))
+	for _, note := range notes {
+		result := SearchResult{NoteID: note.ID, Title: note.Title, Snippets: note.Snippets}
+		if result.Snippets == nil {
+			result.Snippets = []Snippet{}
+		}
+		if note.Rank != nil {
+			result.Score = *note.Rank
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// semanticSearch ranks notes by vector similarity to the query, best first.
+// A note scores as its best chunk, which is returned as the passage.
+func (s *Service) semanticSearch(ctx context.Context, userID, query string, limit int) ([]SearchResult, error) {
 	// Generate embedding for search query
-	vector, err := s.embeddingService.Generate(req.Query)
+	vector, err := s.embeddingService.Generate(query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate query embedding: %w", err)
 	}
 
-	// Search in Qdrant with user filter
-	results, err := s.vectorStore.SearchWithFilter(ctx, vector, userID, uint64(req.Limit))
+	// Search in Qdrant with user filter. Points are chunks, so fetch more than needed
+	// to still find limit notes when several chunks of a note match.
+	results, err := s.vectorStore.SearchWithFilter(ctx, vector, userID, uint64(limit*semanticChunkFactor))
 	if err != nil {
 		return nil, fmt.Errorf("failed to search: %w", err)
 	}
 
-	// Convert results to SearchResult slice
+	// Group chunks by note; results are ordered best first
 	searchResults := []SearchResult{}
+	resultIndex := map[string]int{}
 
 	for _, point := range results {
-		if point == nil {
+		if point == nil || point.Payload == nil {
 			continue
 		}
 
-		result := SearchResult{
-			Score: point.Score,
+		noteID := point.Payload["note_id"].GetStringValue()
+		if noteID == "" {
+			continue
 		}
 
-		// Extract note_id and title from payload
-		if point.Payload != nil {
-			if noteIDVal, ok := point.Payload["note_id"]; ok && noteIDVal != nil {
-				if noteIDVal.GetStringValue() != "" {
-					result.NoteID = noteIDVal.GetStringValue()
-				}
-			}
-			if titleVal, ok := point.Payload["title"]; ok && titleVal != nil {
-				if titleVal.GetStringValue() != "" {
-					result.Title = titleVal.GetStringValue()
-				}
-			}
+		if i, ok := resultIndex[noteID]; ok {
+			searchResults[i].MatchedChunks++
+			continue
+		}
+		if len(searchResults) == limit {
+			continue
 		}
 
+		// Points stored before notes were chunked hold the whole note and have no chunk index
+		content := point.Payload["content"].GetStringValue()
+		passage := &Passage{
+			ChunkIndex: int(point.Payload["chunk_index"].GetIntegerValue()),
+			Start:      int(point.Payload["start"].GetIntegerValue()),
+			End:        len([]rune(content)),
+			Text:       content,
+			Score:      point.Score,
+		}
+		if _, ok := point.Payload["end"]; ok {
+			passage.End = int(point.Payload["end"].GetIntegerValue())
+		}
+
+		result := SearchResult{
+			NoteID:        noteID,
+			Title:         point.Payload["title"].GetStringValue(),
+			Score:         point.Score,
+			Snippets:      []Snippet{},
+			Passage:       passage,
+			MatchedChunks: 1,
+		}
+		if snippet := bestPassageSnippet(content, query); snippet != nil {
+			result.Snippets = append(result.Snippets, *snippet)
+		}
+
+		resultIndex[noteID] = len(searchResults)
 		searchResults = append(searchResults, result)
 	}
 
-	return &SearchResponse{
-		Results: searchResults,
-		Query:   req.Query,
-	}, nil
+	return searchResults, nil
 }
 
 func (s *Service) GetVectorSpace(
@@ -505,48 +1285,73 @@ func (s *Service) GetVectorSpace(
 		limit = 10000
 	}
 
-	// Get all user points from Qdrant with vectors
-	points, err := s.vectorStore.GetAllUserPoints(ctx, userID, uint64(limit))
+	// Get all user points from Qdrant with vectors; notes have one point per chunk
+	points, err := s.vectorStore.GetAllUserPoints(ctx, userID, uint64(min(limit*semanticChunkFactor, 10000)))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user points: %w", err)
 	}
 
-	vectorPoints := make([]VectorPoint, 0, len(points))
+	primaryTags, groups, err := s.primaryTagGroups(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// A note is placed at the mean of its chunk vectors
+	vectorPoints := []VectorPoint{}
+	pointIndex := map[string]int{}
+	chunkCounts := []int{}
 
 	for _, point := range points {
-		if point == nil || point.Vectors == nil {
+		if point == nil || point.Vectors == nil || point.Payload == nil {
 			continue
 		}
 
-		vp := VectorPoint{}
-
-		// Extract vector data
-		if vectorData := point.Vectors.GetVector(); vectorData != nil {
-			vp.Vector = vectorData.Data
+		vectorData := point.Vectors.GetVector()
+		noteID := point.Payload["note_id"].GetStringValue()
+		if vectorData == nil || noteID == "" {
+			continue
 		}
 
-		// Extract payload data
-		if point.Payload != nil {
-			if noteIDVal, ok := point.Payload["note_id"]; ok && noteIDVal != nil {
-				vp.NoteID = noteIDVal.GetStringValue()
-			}
-			if titleVal, ok := point.Payload["title"]; ok && titleVal != nil {
-				vp.Title = titleVal.GetStringValue()
-			}
-			if createdAtVal, ok := point.Payload["created_at"]; ok && createdAtVal != nil {
-				vp.CreatedAt = time.Time{}
+		if i, ok := pointIndex[noteID]; ok {
+			if len(vectorData.Data) == len(vectorPoints[i].Vector) {
+				for j, value := range vectorData.Data {
+					vectorPoints[i].Vector[j] += value
+				}
+				chunkCounts[i]++
 			}
+			continue
+		}
+		if len(vectorPoints) == limit {
+			continue
+		}
+
+		vp := VectorPoint{
+			NoteID: noteID,
+			Title:  point.Payload["title"].GetStringValue(),
+			Vector: append([]float32(nil), vectorData.Data...),
 		}
 
 		// Get note details including tags
-		if vp.NoteID != "" {
-			tags, err := s.noteRepo.GetNoteTags(ctx, vp.NoteID)
-			if err == nil {
-				vp.Tags = tags
-			}
+		tags, err := s.noteRepo.GetNoteTags(ctx, vp.NoteID)
+		if err == nil {
+			vp.Tags = tags
+		}
+
+		if tag, ok := primaryTags[vp.NoteID]; ok {
+			vp.Group = groups[tag.ID]
+			vp.Tag = &tag.Name
+			vp.Color = tag.Color
 		}
 
+		pointIndex[noteID] = len(vectorPoints)
 		vectorPoints = append(vectorPoints, vp)
+		chunkCounts = append(chunkCounts, 1)
+	}
+
+	for i := range vectorPoints {
+		for j := range vectorPoints[i].Vector {
+			vectorPoints[i].Vector[j] /= float32(chunkCounts[i])
+		}
 	}
 
 	return &VectorSpaceResponse{
@@ -564,7 +1369,7 @@ func (s *Service) ensureTagsExist(
 	tagIDs := make([]string, 0, len(tagNames))
 
 	for _, name := range tagNames {
-		tag, err := s.tagRepo.FindOrCreateByName(ctx, userID, name)
+		tag, err := s.ensureTagPath(ctx, userID, name)
 		if err != nil {
 			return nil, err
 		}
@@ -574,6 +1379,41 @@ func (s *Service) ensureTagsExist(
 	return tagIDs, nil
 }
 
+// ensureTagPath finds or creates a tag together with its ancestors,
+// e.g. "lang" and "lang/go" for "lang/go/concurrency"
+func (s *Service) ensureTagPath(ctx context.Context, userID, name string) (*Tag, error) {
+	segments := strings.Split(normalizeTagPath(name), "/")
+
+	var tag *Tag
+	for i := range segments {
+		var parentID *string
+		if tag != nil {
+			parentID = &tag.ID
+		}
+
+		next, err := s.tagRepo.FindOrCreateByName(ctx, userID, strings.Join(segments[:i+1], "/"), parentID)
+		if err != nil {
+			return nil, err
+		}
+		tag = next
+	}
+
+	return tag, nil
+}
+
+// normalizeTagPath lowercases a tag name and trims its path segments, dropping empty ones
+func normalizeTagPath(name string) string {
+	segments := []string{}
+	for _, segment := range strings.Split(name, "/") {
+		segment = strings.ToLower(strings.TrimSpace(segment))
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
+
 // processNoteLinks extracts [[note-title]] links and creates link records
 func (s *Service) processNoteLinks(ctx context.Context, userID, noteID, content string) error {
 	// Delete existing links for this note
@@ -638,6 +1478,20 @@ func (s *Service) GetGraph(ctx context.Context, userID string) (*GraphResponse,
 		return nil, fmt.Errorf("failed to get graph data: %w", err)
 	}
 
+	// Colour nodes by their primary tag
+	primaryTags, groups, err := s.primaryTagGroups(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range nodes {
+		if tag, ok := primaryTags[nodes[i].ID]; ok {
+			nodes[i].Group = groups[tag.ID]
+			nodes[i].Tag = &tag.Name
+			nodes[i].Color = tag.Color
+		}
+	}
+
 	return &GraphResponse{
 		Nodes: nodes,
 		Links: links,
@@ -659,18 +1513,9 @@ func (s *Service) ShareNote(
 
 	var publicSlug *string
 	if isPublic {
-		// Generate slug from title if sharing
-		slug := generateSlug(note.Title, noteID)
-
-		// Check if slug is available
-		available, err := s.noteRepo.IsSlugAvailable(ctx, slug)
+		slug, err := s.availableSlug(ctx, note)
 		if err != nil {
-			return nil, fmt.Errorf("failed to check slug availability: %w", err)
-		}
-
-		// If not available, add a suffix
-		if !available {
-			slug = fmt.Sprintf("%s-%s", slug, noteID[:8])
+			return nil, err
 		}
 
 		publicSlug = &slug
@@ -696,6 +1541,55 @@ func (s *Service) ShareNote(
 	return response, nil
 }
 
+// availableSlug generates a public slug from the note title that no other note uses
+func (s *Service) availableSlug(ctx context.Context, note *Note) (string, error) {
+	slug := generateSlug(note.Title, note.ID)
+
+	// Check if slug is available
+	available, err := s.noteRepo.IsSlugAvailable(ctx, slug)
+	if err != nil {
+		return "", fmt.Errorf("failed to check slug availability: %w", err)
+	}
+
+	// If not available, add a suffix
+	if !available {
+		slug = fmt.Sprintf("%s-%s", slug, note.ID[:8])
+	}
+
+	return slug, nil
+}
+
+// userPreferences returns the user's preferences with server defaults filled in.
+// Preferences are a convenience, so lookup failures fall back to the defaults.
+func (s *Service) userPreferences(ctx context.Context, userID string) Preferences {
+	prefs := Preferences{
+		PageSize:       s.defaultPageSize,
+		SearchLanguage: DefaultSearchLanguage,
+	}
+
+	if s.preferences == nil {
+		return prefs
+	}
+
+	userPrefs, err := s.preferences.NotePreferences(ctx, userID)
+	if err != nil {
+		fmt.Printf("Warning: failed to load preferences for user %s: %v\n", userID, err)
+		return prefs
+	}
+
+	if userPrefs.PageSize > 0 {
+		prefs.PageSize = userPrefs.PageSize
+	}
+
+	if slices.Contains(SearchLanguages, userPrefs.SearchLanguage) {
+		prefs.SearchLanguage = userPrefs.SearchLanguage
+	}
+
+	prefs.PublicByDefault = userPrefs.PublicByDefault
+
+	return prefs
+}
+
 // GetPublicNote retrieves a public note by slug and increments view count
 func (s *Service) GetPublicNote(ctx context.Context, slug string) (*PublicNoteResponse, error) {
 	// Find public note
@@ -731,6 +1625,140 @@ func (s *Service) GetPublicNote(ctx context.Context, slug string) (*PublicNoteRe
 	}, nil
 }
 
+// ============================================================
+// Trash Methods
+// ============================================================
+
+// ListTrash returns a page of the user's trashed notes
+func (s *Service) ListTrash(ctx context.Context, userID string, page, perPage int) (*ListTrashResponse, error) {
+	if page < 1 {
+		page = 1
+	}
+
+	if perPage < 1 {
+		perPage = s.userPreferences(ctx, userID).PageSize
+	}
+
+	if perPage > s.maxPageSize {
+		perPage = s.maxPageSize
+	}
+
+	notes, total, err := s.noteRepo.ListTrashed(ctx, userID, page, perPage)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range notes {
+		tags, err := s.noteRepo.GetNoteTags(ctx, notes[i].ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get note tags: %w", err)
+		}
+		notes[i].Tags = tags
+	}
+
+	return &ListTrashResponse{
+		Notes:         notes,
+		Total:         total,
+		Page:          page,
+		PerPage:       perPage,
+		TotalPages:    int(math.Ceil(float64(total) / float64(perPage))),
+		RetentionDays: int(s.trashRetention.Hours() / 24),
+	}, nil
+}
+
+// RestoreNote takes a note out of the trash and makes it searchable again
+func (s *Service) RestoreNote(ctx context.Context, userID, noteID string) (*Note, error) {
+	note, err := s.noteRepo.Restore(ctx, userID, noteID)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := s.noteRepo.GetNoteTags(ctx, noteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get note tags: %w", err)
+	}
+	note.Tags = tags
+
+	go s.setTrashed([]string{noteID}, false)
+
+	return note, nil
+}
+
+// DeleteNotePermanently removes a trashed note with its version history
+func (s *Service) DeleteNotePermanently(ctx context.Context, userID, noteID string) error {
+	if err := s.noteRepo.Delete(ctx, userID, noteID); err != nil {
+		return err
+	}
+
+	go s.deletePoints([]string{noteID})
+
+	return nil
+}
+
+// EmptyTrash permanently removes all of the user's trashed notes and returns how many
+func (s *Service) EmptyTrash(ctx context.Context, userID string) (int, error) {
+	noteIDs, err := s.noteRepo.EmptyTrash(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	go s.deletePoints(noteIDs)
+
+	return len(noteIDs), nil
+}
+
+// PurgeTrash permanently removes notes that have been in the trash longer than the
+// retention period and returns how many were removed
+func (s *Service) PurgeTrash(ctx context.Context) (int, error) {
+	noteIDs, err := s.noteRepo.PurgeTrashed(ctx, time.Now().Add(-s.trashRetention))
+	if err != nil {
+		return 0, err
+	}
+
+	s.deletePoints(noteIDs)
+
+	return len(noteIDs), nil
+}
+
+// RunTrashPurge purges expired trash every interval until ctx is cancelled
+func (s *Service) RunTrashPurge(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := s.PurgeTrash(ctx)
+		if err != nil {
+			fmt.Printf("Warning: failed to purge trash: %v\n", err)
+		} else if purged > 0 {
+			fmt.Printf("Purged %d notes from the trash\n", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// setTrashed hides or shows the notes' vectors in search; failures are only logged
+func (s *Service) setTrashed(noteIDs []string, trashed bool) {
+	for _, noteID := range noteIDs {
+		if err := s.vectorStore.SetNoteTrashed(context.Background(), noteID, trashed); err != nil {
+			fmt.Printf("Warning: failed to update note %s in vector store: %v\n", noteID, err)
+		}
+	}
+}
+
+// deletePoints removes the notes' vectors; failures are only logged
+func (s *Service) deletePoints(noteIDs []string) {
+	for _, noteID := range noteIDs {
+		if err := s.vectorStore.DeleteNoteChunks(context.Background(), noteID, 0); err != nil {
+			fmt.Printf("Warning: failed to delete note %s from vector store: %v\n", noteID, err)
+		}
+	}
+}
+
 // ============================================================
 // Version History Methods
 // ============================================================
@@ -788,6 +1816,11 @@ func (s *Service) GetVersionDiff(
 		return nil, fmt.Errorf("new version not found: %w", err)
 	}
 
+	return diffVersions(oldVersion, newVersion), nil
+}
+
+// diffVersions compares the title, content and tags of two versions
+func diffVersions(oldVersion, newVersion *NoteVersion) *VersionDiff {
 	// Check if title changed
 	titleChanged := oldVersion.Title != newVersion.Title
 
@@ -804,7 +1837,7 @@ func (s *Service) GetVersionDiff(
 		ContentDiff:  contentDiff,
 		TagsAdded:    tagsAdded,
 		TagsRemoved:  tagsRemoved,
-	}, nil
+	}
 }
 
 // RestoreVersion restores a note to a previous version
@@ -829,7 +1862,13 @@ func (s *Service) RestoreVersion(
 		return nil, fmt.Errorf("version does not belong to this note")
 	}
 
-	// Update the note to match the old version
+	// Resolve the old tags, recreating any that were deleted since
+	tagIDs, err := s.ensureTagsExist(ctx, userID, uniqueStrings(version.Tags))
+	if err != nil {
+		return nil, fmt.Errorf("failed to process tags: %w", err)
+	}
+
+	// Update the note and its tags to match the old version
 	// This will automatically create a new version via the database trigger
 	note, err := s.noteRepo.Update(
 		ctx,
@@ -838,26 +1877,13 @@ func (s *Service) RestoreVersion(
 		&version.Title,
 		&version.ContentMd,
 		version.SourceURL,
+		tagIDs,
+		0,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to restore version: %w", err)
 	}
 
-	// Update tags to match the old version
-	if len(version.Tags) > 0 {
-		tagObjects, err := s.tagRepo.FindByNames(ctx, userID, version.Tags)
-		if err == nil && len(tagObjects) > 0 {
-			tagIDs := make([]string, len(tagObjects))
-			for i, tag := range tagObjects {
-				tagIDs[i] = tag.ID
-			}
-			_ = s.tagRepo.SetNoteTags(ctx, noteID, tagIDs)
-		}
-	} else {
-		// Clear all tags if version had no tags
-		_ = s.tagRepo.SetNoteTags(ctx, noteID, []string{})
-	}
-
 	// Re-index in vector store
 	go func() {
 		indexCtx := context.Background()
@@ -0,0 +1,192 @@
+package notes
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Snippet is a short excerpt of a note's content showing why it matched a search
+type Snippet struct {
+	Text       string      `json:"text"`
+	Highlights []Highlight `json:"highlights"` // Matched words in Text
+}
+
+// Highlight marks a match in a snippet. Offsets count Unicode characters; End is exclusive.
+type Highlight struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// Snippet settings
+const (
+	maxSnippetWords = 35
+	maxSnippets     = 3
+)
+
+// Control characters that ts_headline wraps matches and separates fragments with.
+// They do not occur in normal text, so they can be turned back into offsets.
+const (
+	headlineStart     = "\x02"
+	headlineStop      = "\x03"
+	headlineDelimiter = "\x1f"
+)
+
+// headlineOptions configures ts_headline to return up to maxSnippets fragments
+var headlineOptions = fmt.Sprintf(
+	`StartSel="%s", StopSel="%s", FragmentDelimiter="%s", MaxFragments=%d, MaxWords=%d, MinWords=%d`,
+	headlineStart, headlineStop, headlineDelimiter, maxSnippets, maxSnippetWords, maxSnippetWords/2,
+)
+
+// parseHeadline turns ts_headline output produced with headlineOptions into snippets
+func parseHeadline(headline string) []Snippet {
+	snippets := []Snippet{}
+
+	for _, fragment := range strings.Split(headline, headlineDelimiter) {
+		snippet := Snippet{Highlights: []Highlight{}}
+		var text strings.Builder
+		offset := 0
+		start := -1
+
+		for _, r := range fragment {
+			switch string(r) {
+			case headlineStart:
+				start = offset
+			case headlineStop:
+				if start >= 0 && offset > start {
+					snippet.Highlights = append(snippet.Highlights, Highlight{Start: start, End: offset})
+				}
+				start = -1
+			default:
+				if r == '\n' || r == '\r' || r == '\t' {
+					r = ' '
+				}
+				text.WriteRune(r)
+				offset++
+			}
+		}
+
+		snippet.Text = text.String()
+		if strings.TrimSpace(snippet.Text) != "" {
+			snippets = append(snippets, snippet)
+		}
+	}
+
+	return snippets
+}
+
+// bestPassageSnippet picks the passage of content that shares the most words with the query.
+// Used for vector search hits, which have no full-text match to build a headline from.
+func bestPassageSnippet(content, query string) *Snippet {
+	terms := snippetTerms(query)
+
+	var best *Snippet
+	bestScore := -1
+	for _, passage := range strings.Split(content, "\n\n") {
+		if strings.TrimSpace(passage) == "" {
+			continue
+		}
+		snippet, score := passageSnippet(passage, terms)
+		if score > bestScore {
+			best, bestScore = snippet, score
+		}
+	}
+
+	return best
+}
+
+// passageSnippet cuts the window of at most maxSnippetWords words that matches the most
+// query terms out of a passage and returns it with the number of distinct terms it matches
+func passageSnippet(passage string, terms []string) (*Snippet, int) {
+	runes := []rune(passage)
+	words := wordSpans(runes)
+	if len(words) == 0 {
+		return &Snippet{Text: strings.TrimSpace(passage), Highlights: []Highlight{}}, 0
+	}
+
+	matches := make([]string, len(words)) // Term matched by each word, if any
+	for i, word := range words {
+		matches[i] = matchingTerm(strings.ToLower(string(runes[word.Start:word.End])), terms)
+	}
+
+	// Slide a window over the words and keep the one matching the most distinct terms
+	from, to, score := 0, min(len(words), maxSnippetWords), -1
+	for start := 0; start == 0 || start+maxSnippetWords <= len(words); start++ {
+		end := min(len(words), start+maxSnippetWords)
+		if n := distinctTerms(matches[start:end]); n > score {
+			from, to, score = start, end, n
+		}
+	}
+
+	window := runes[words[from].Start:words[to-1].End]
+	text := strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\r' || r == '\t' {
+			return ' '
+		}
+		return r
+	}, string(window))
+
+	snippet := &Snippet{Text: text, Highlights: []Highlight{}}
+	for i := from; i < to; i++ {
+		if matches[i] != "" {
+			snippet.Highlights = append(snippet.Highlights, Highlight{
+				Start: words[i].Start - words[from].Start,
+				End:   words[i].End - words[from].Start,
+			})
+		}
+	}
+
+	return snippet, score
+}
+
+// snippetTerms returns the distinct lower-case words of a query
+func snippetTerms(query string) []string {
+	runes := []rune(query)
+	terms := []string{}
+	for _, word := range wordSpans(runes) {
+		if word.End-word.Start >= 2 {
+			terms = append(terms, strings.ToLower(string(runes[word.Start:word.End])))
+		}
+	}
+	return uniqueStrings(terms)
+}
+
+// matchingTerm returns the term a word starts with, so "contexts" matches "context"
+func matchingTerm(word string, terms []string) string {
+	for _, term := range terms {
+		if strings.HasPrefix(word, term) {
+			return term
+		}
+	}
+	return ""
+}
+
+func distinctTerms(matches []string) int {
+	seen := map[string]struct{}{}
+	for _, match := range matches {
+		if match != "" {
+			seen[match] = struct{}{}
+		}
+	}
+	return len(seen)
+}
+
+// wordSpans returns the positions of the runs of letters and digits in text
+func wordSpans(text []rune) []Highlight {
+	spans := []Highlight{}
+	start := -1
+	for i, r := range text {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if isWord && start < 0 {
+			start = i
+		}
+		if !isWord && start >= 0 {
+			spans = append(spans, Highlight{Start: start, End: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, Highlight{Start: start, End: len(text)})
+	}
+	return spans
+}
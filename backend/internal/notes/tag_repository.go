@@ -19,7 +19,16 @@ func NewPostgresTagRepository(db *pgxpool.Pool) *PostgresTagRepository {
 	return &PostgresTagRepository{db: db}
 }
 
-func (r *PostgresTagRepository) FindOrCreateByName(ctx context.Context, userID, name string) (*Tag, error) {
+// tagFields returns the scan destinations for the tag columns selected by this repository
+func tagFields(tag *Tag) []interface{} {
+	return []interface{}{
+		&tag.ID, &tag.UserID, &tag.Name, &tag.ParentID,
+		&tag.Color, &tag.Icon, &tag.Description, &tag.Pinned, &tag.CreatedAt,
+	}
+}
+
+// FindOrCreateByName returns the tag with the given full path name, creating it under parentID if needed
+func (r *PostgresTagRepository) FindOrCreateByName(ctx context.Context, userID, name string, parentID *string) (*Tag, error) {
 	// Normalize tag name (lowercase, trim)
 	normalizedName := strings.ToLower(strings.TrimSpace(name))
 	if normalizedName == "" {
@@ -28,10 +37,18 @@ func (r *PostgresTagRepository) FindOrCreateByName(ctx context.Context, userID,
 
 	// Try to find existing tag for this user
 	tag := &Tag{}
-	query := `SELECT id, user_id, name, created_at FROM tags WHERE user_id = $1 AND name = $2`
-	err := r.db.QueryRow(ctx, query, userID, normalizedName).Scan(&tag.ID, &tag.UserID, &tag.Name, &tag.CreatedAt)
+	query := `SELECT id, user_id, name, parent_id, color, icon, description, pinned, created_at FROM tags WHERE user_id = $1 AND name = $2`
+	err := r.db.QueryRow(ctx, query, userID, normalizedName).Scan(tagFields(tag)...)
 
 	if err == nil {
+		// Link tags whose parent was deleted or created later
+		if tag.ParentID == nil && parentID != nil {
+			_, err = r.db.Exec(ctx, `UPDATE tags SET parent_id = $1 WHERE id = $2`, *parentID, tag.ID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to set tag parent: %w", err)
+			}
+			tag.ParentID = parentID
+		}
 		return tag, nil
 	}
 
@@ -44,16 +61,17 @@ func (r *PostgresTagRepository) FindOrCreateByName(ctx context.Context, userID,
 		ID:        uuid.New().String(),
 		UserID:    userID,
 		Name:      normalizedName,
+		ParentID:  parentID,
 		CreatedAt: time.Now(),
 	}
 
 	insertQuery := `
-		INSERT INTO tags (id, user_id, name, created_at)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id, user_id, name, created_at
+		INSERT INTO tags (id, user_id, name, parent_id, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, user_id, name, parent_id, color, icon, description, pinned, created_at
 	`
 
-	err = r.db.QueryRow(ctx, insertQuery, tag.ID, tag.UserID, tag.Name, tag.CreatedAt).Scan(&tag.ID, &tag.UserID, &tag.Name, &tag.CreatedAt)
+	err = r.db.QueryRow(ctx, insertQuery, tag.ID, tag.UserID, tag.Name, tag.ParentID, tag.CreatedAt).Scan(tagFields(tag)...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create tag: %w", err)
 	}
@@ -66,7 +84,7 @@ func (r *PostgresTagRepository) FindByIDs(ctx context.Context, userID string, ta
 		return []Tag{}, nil
 	}
 
-	query := `SELECT id, user_id, name, created_at FROM tags WHERE user_id = $1 AND id = ANY($2)`
+	query := `SELECT id, user_id, name, parent_id, color, icon, description, pinned, created_at FROM tags WHERE user_id = $1 AND id = ANY($2)`
 	rows, err := r.db.Query(ctx, query, userID, tagIDs)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find tags: %w", err)
@@ -76,7 +94,7 @@ func (r *PostgresTagRepository) FindByIDs(ctx context.Context, userID string, ta
 	tags := []Tag{}
 	for rows.Next() {
 		var tag Tag
-		if err := rows.Scan(&tag.ID, &tag.UserID, &tag.Name, &tag.CreatedAt); err != nil {
+		if err := rows.Scan(tagFields(&tag)...); err != nil {
 			return nil, fmt.Errorf("failed to scan tag: %w", err)
 		}
 		tags = append(tags, tag)
@@ -96,7 +114,7 @@ func (r *PostgresTagRepository) FindByNames(ctx context.Context, userID string,
 		normalizedNames[i] = strings.ToLower(strings.TrimSpace(name))
 	}
 
-	query := `SELECT id, user_id, name, created_at FROM tags WHERE user_id = $1 AND name = ANY($2)`
+	query := `SELECT id, user_id, name, parent_id, color, icon, description, pinned, created_at FROM tags WHERE user_id = $1 AND name = ANY($2)`
 	rows, err := r.db.Query(ctx, query, userID, normalizedNames)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find tags: %w", err)
@@ -106,7 +124,7 @@ func (r *PostgresTagRepository) FindByNames(ctx context.Context, userID string,
 	tags := []Tag{}
 	for rows.Next() {
 		var tag Tag
-		if err := rows.Scan(&tag.ID, &tag.UserID, &tag.Name, &tag.CreatedAt); err != nil {
+		if err := rows.Scan(tagFields(&tag)...); err != nil {
 			return nil, fmt.Errorf("failed to scan tag: %w", err)
 		}
 		tags = append(tags, tag)
@@ -149,23 +167,8 @@ func (r *PostgresTagRepository) SetNoteTags(ctx context.Context, noteID string,
 	}
 	defer tx.Rollback(ctx)
 
-	// Remove all existing tags
-	_, err = tx.Exec(ctx, `DELETE FROM note_tags WHERE note_id = $1`, noteID)
-	if err != nil {
-		return fmt.Errorf("failed to remove existing tags: %w", err)
-	}
-
-	// Add new tags
-	if len(tagIDs) > 0 {
-		for _, tagID := range tagIDs {
-			_, err = tx.Exec(ctx, `
-				INSERT INTO note_tags (note_id, tag_id, created_at)
-				VALUES ($1, $2, $3)
-			`, noteID, tagID, time.Now())
-			if err != nil {
-				return fmt.Errorf("failed to assign tag: %w", err)
-			}
-		}
+	if _, err := setNoteTags(ctx, tx, noteID, tagIDs); err != nil {
+		return err
 	}
 
 	// Commit transaction
@@ -176,8 +179,37 @@ func (r *PostgresTagRepository) SetNoteTags(ctx context.Context, noteID string,
 	return nil
 }
 
+// setNoteTags replaces the tags of a note within tx and reports whether they changed
+func setNoteTags(ctx context.Context, tx pgx.Tx, noteID string, tagIDs []string) (bool, error) {
+	if tagIDs == nil {
+		tagIDs = []string{} // A NULL array would keep every tag
+	}
+
+	// Remove tags that are no longer set; kept tags keep their created_at for tag analytics
+	result, err := tx.Exec(ctx, `DELETE FROM note_tags WHERE note_id = $1 AND NOT (tag_id = ANY($2))`, noteID, tagIDs)
+	if err != nil {
+		return false, fmt.Errorf("failed to remove existing tags: %w", err)
+	}
+	changed := result.RowsAffected() > 0
+
+	// Add new tags
+	for _, tagID := range tagIDs {
+		result, err := tx.Exec(ctx, `
+			INSERT INTO note_tags (note_id, tag_id, created_at)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (note_id, tag_id) DO NOTHING
+		`, noteID, tagID, time.Now())
+		if err != nil {
+			return false, fmt.Errorf("failed to assign tag: %w", err)
+		}
+		changed = changed || result.RowsAffected() > 0
+	}
+
+	return changed, nil
+}
+
 func (r *PostgresTagRepository) ListAll(ctx context.Context, userID string) ([]Tag, error) {
-	query := `SELECT id, user_id, name, created_at FROM tags WHERE user_id = $1 ORDER BY name`
+	query := `SELECT id, user_id, name, parent_id, color, icon, description, pinned, created_at FROM tags WHERE user_id = $1 ORDER BY name`
 
 	rows, err := r.db.Query(ctx, query, userID)
 	if err != nil {
@@ -188,7 +220,7 @@ func (r *PostgresTagRepository) ListAll(ctx context.Context, userID string) ([]T
 	tags := []Tag{}
 	for rows.Next() {
 		var tag Tag
-		if err := rows.Scan(&tag.ID, &tag.UserID, &tag.Name, &tag.CreatedAt); err != nil {
+		if err := rows.Scan(tagFields(&tag)...); err != nil {
 			return nil, fmt.Errorf("failed to scan tag: %w", err)
 		}
 		tags = append(tags, tag)
@@ -207,10 +239,215 @@ func (r *PostgresTagRepository) CountAll(ctx context.Context, userID string) (in
 	return count, nil
 }
 
+// ListTree returns the user's tags with their own and rolled-up note counts.
+// Children are not linked; the caller builds the tree from ParentID.
+func (r *PostgresTagRepository) ListTree(ctx context.Context, userID string) ([]*TagNode, error) {
+	query := `
+		WITH RECURSIVE subtree AS (
+			SELECT id AS root_id, id AS tag_id FROM tags WHERE user_id = $1
+			UNION
+			SELECT s.root_id, t.id
+			FROM tags t
+			INNER JOIN subtree s ON t.parent_id = s.tag_id
+		),
+		live_note_tags AS (
+			SELECT nt.tag_id, nt.note_id
+			FROM note_tags nt
+			INNER JOIN notes n ON n.id = nt.note_id
+			WHERE n.user_id = $1 AND n.deleted_at IS NULL
+		)
+		SELECT t.id, t.user_id, t.name, t.parent_id, t.color, t.icon, t.description, t.pinned, t.created_at,
+		       (SELECT COUNT(*) FROM live_note_tags l WHERE l.tag_id = t.id),
+		       (SELECT COUNT(DISTINCT l.note_id)
+		        FROM subtree s
+		        INNER JOIN live_note_tags l ON l.tag_id = s.tag_id
+		        WHERE s.root_id = t.id)
+		FROM tags t
+		WHERE t.user_id = $1
+		ORDER BY t.name
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	defer rows.Close()
+
+	nodes := []*TagNode{}
+	for rows.Next() {
+		node := &TagNode{Children: []*TagNode{}}
+		fields := append(tagFields(&node.Tag), &node.NoteCount, &node.TotalNoteCount)
+		if err := rows.Scan(fields...); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		nodes = append(nodes, node)
+	}
+
+	return nodes, nil
+}
+
+// FindDescendantIDs returns the given tag IDs together with the IDs of all their descendants
+func (r *PostgresTagRepository) FindDescendantIDs(ctx context.Context, userID string, tagIDs []string) ([]string, error) {
+	if len(tagIDs) == 0 {
+		return []string{}, nil
+	}
+
+	query := `
+		WITH RECURSIVE subtree AS (
+			SELECT id FROM tags WHERE user_id = $1 AND id = ANY($2)
+			UNION
+			SELECT t.id
+			FROM tags t
+			INNER JOIN subtree s ON t.parent_id = s.id
+		)
+		SELECT id FROM subtree
+	`
+
+	rows, err := r.db.Query(ctx, query, userID, tagIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find descendant tags: %w", err)
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan tag ID: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// UpdateMetadata saves the tag's colour, icon, description and pinned flag
+func (r *PostgresTagRepository) UpdateMetadata(ctx context.Context, tag *Tag) error {
+	query := `
+		UPDATE tags SET color = $1, icon = $2, description = $3, pinned = $4
+		WHERE id = $5 AND user_id = $6
+	`
+
+	result, err := r.db.Exec(ctx, query, tag.Color, tag.Icon, tag.Description, tag.Pinned, tag.ID, tag.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to update tag: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("tag not found")
+	}
+
+	return nil
+}
+
+// FindPrimaryTags returns the tag that represents each of the user's notes, keyed by note ID.
+// Pinned tags come first, then the tag that was assigned first. Untagged notes are left out.
+func (r *PostgresTagRepository) FindPrimaryTags(ctx context.Context, userID string) (map[string]Tag, error) {
+	query := `
+		SELECT DISTINCT ON (nt.note_id) nt.note_id,
+		       t.id, t.user_id, t.name, t.parent_id, t.color, t.icon, t.description, t.pinned, t.created_at
+		FROM note_tags nt
+		INNER JOIN tags t ON t.id = nt.tag_id
+		INNER JOIN notes n ON n.id = nt.note_id
+		WHERE n.user_id = $1 AND n.deleted_at IS NULL
+		ORDER BY nt.note_id, t.pinned DESC, nt.created_at, t.name
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find primary tags: %w", err)
+	}
+	defer rows.Close()
+
+	primary := make(map[string]Tag)
+	for rows.Next() {
+		var noteID string
+		var tag Tag
+		fields := append([]interface{}{&noteID}, tagFields(&tag)...)
+		if err := rows.Scan(fields...); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		primary[noteID] = tag
+	}
+
+	return primary, nil
+}
+
+// CoOccurrences counts, for every pair of tags used together, the notes that carry both.
+// Tag names are not filled in.
+func (r *PostgresTagRepository) CoOccurrences(ctx context.Context, userID string) ([]TagPair, error) {
+	query := `
+		WITH live_note_tags AS (
+			SELECT nt.note_id, nt.tag_id
+			FROM note_tags nt
+			INNER JOIN notes n ON n.id = nt.note_id
+			WHERE n.user_id = $1 AND n.deleted_at IS NULL
+		)
+		SELECT a.tag_id, b.tag_id, COUNT(*)
+		FROM live_note_tags a
+		INNER JOIN live_note_tags b ON a.note_id = b.note_id AND a.tag_id < b.tag_id
+		GROUP BY a.tag_id, b.tag_id
+		ORDER BY COUNT(*) DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count tag pairs: %w", err)
+	}
+	defer rows.Close()
+
+	pairs := []TagPair{}
+	for rows.Next() {
+		var pair TagPair
+		if err := rows.Scan(&pair.TagAID, &pair.TagBID, &pair.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan tag pair: %w", err)
+		}
+		pairs = append(pairs, pair)
+	}
+
+	return pairs, nil
+}
+
+// WeeklyUsage counts the notes tagged per tag and week since the given time.
+// Only weeks with at least one tagged note are returned; tag names are not filled in.
+func (r *PostgresTagRepository) WeeklyUsage(ctx context.Context, userID string, since time.Time) ([]TagUsage, error) {
+	query := `
+		SELECT nt.tag_id, date_trunc('week', nt.created_at) AS week, COUNT(*)
+		FROM note_tags nt
+		INNER JOIN notes n ON n.id = nt.note_id
+		WHERE n.user_id = $1 AND n.deleted_at IS NULL AND nt.created_at >= $2
+		GROUP BY nt.tag_id, week
+		ORDER BY nt.tag_id, week
+	`
+
+	rows, err := r.db.Query(ctx, query, userID, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tag usage: %w", err)
+	}
+	defer rows.Close()
+
+	usage := []TagUsage{}
+	for rows.Next() {
+		var tagID string
+		var week WeekCount
+		if err := rows.Scan(&tagID, &week.Week, &week.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan tag usage: %w", err)
+		}
+
+		if len(usage) == 0 || usage[len(usage)-1].TagID != tagID {
+			usage = append(usage, TagUsage{TagID: tagID})
+		}
+		last := &usage[len(usage)-1]
+		last.Weeks = append(last.Weeks, week)
+	}
+
+	return usage, nil
+}
+
 func (r *PostgresTagRepository) FindByID(ctx context.Context, userID, tagID string) (*Tag, error) {
 	tag := &Tag{}
-	query := `SELECT id, user_id, name, created_at FROM tags WHERE user_id = $1 AND id = $2`
-	err := r.db.QueryRow(ctx, query, userID, tagID).Scan(&tag.ID, &tag.UserID, &tag.Name, &tag.CreatedAt)
+	query := `SELECT id, user_id, name, parent_id, color, icon, description, pinned, created_at FROM tags WHERE user_id = $1 AND id = $2`
+	err := r.db.QueryRow(ctx, query, userID, tagID).Scan(tagFields(tag)...)
 	
 	if err == pgx.ErrNoRows {
 		return nil, fmt.Errorf("tag not found")
@@ -228,7 +465,7 @@ func (r *PostgresTagRepository) GetNotesCountByTag(ctx context.Context, userID,
 		SELECT COUNT(DISTINCT n.id)
 		FROM notes n
 		INNER JOIN note_tags nt ON n.id = nt.note_id
-		WHERE n.user_id = $1 AND nt.tag_id = $2
+		WHERE n.user_id = $1 AND nt.tag_id = $2 AND n.deleted_at IS NULL
 	`
 	err := r.db.QueryRow(ctx, query, userID, tagID).Scan(&count)
 	if err != nil {
@@ -237,7 +474,35 @@ func (r *PostgresTagRepository) GetNotesCountByTag(ctx context.Context, userID,
 	return count, nil
 }
 
-func (r *PostgresTagRepository) Delete(ctx context.Context, userID, tagID string) ([]string, error) {
+// FindNotesByTag returns the notes (outside the trash) that carry the tag
+func (r *PostgresTagRepository) FindNotesByTag(ctx context.Context, userID, tagID string) ([]LinkedNote, error) {
+	query := `
+		SELECT n.id, n.title
+		FROM notes n
+		INNER JOIN note_tags nt ON n.id = nt.note_id
+		WHERE n.user_id = $1 AND nt.tag_id = $2 AND n.deleted_at IS NULL
+		ORDER BY n.title
+	`
+	rows, err := r.db.Query(ctx, query, userID, tagID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notes with tag: %w", err)
+	}
+	defer rows.Close()
+
+	notes := []LinkedNote{}
+	for rows.Next() {
+		var note LinkedNote
+		if err := rows.Scan(&note.ID, &note.Title); err != nil {
+			return nil, fmt.Errorf("failed to scan note: %w", err)
+		}
+		notes = append(notes, note)
+	}
+
+	return notes, nil
+}
+
+// Delete removes the tag and, depending on mode, trashes its notes or moves them to targetTagID
+func (r *PostgresTagRepository) Delete(ctx context.Context, userID, tagID, mode, targetTagID string) ([]string, error) {
 	// Start transaction
 	tx, err := r.db.Begin(ctx)
 	if err != nil {
@@ -245,12 +510,16 @@ func (r *PostgresTagRepository) Delete(ctx context.Context, userID, tagID string
 	}
 	defer tx.Rollback(ctx)
 
+	if err := ensureNoChildTags(ctx, tx, []string{tagID}); err != nil {
+		return nil, err
+	}
+
 	// First, get all notes that have this tag
 	noteIDsQuery := `
 		SELECT DISTINCT n.id
 		FROM notes n
 		INNER JOIN note_tags nt ON n.id = nt.note_id
-		WHERE n.user_id = $1 AND nt.tag_id = $2
+		WHERE n.user_id = $1 AND nt.tag_id = $2 AND n.deleted_at IS NULL
 	`
 	rows, err := tx.Query(ctx, noteIDsQuery, userID, tagID)
 	if err != nil {
@@ -268,16 +537,34 @@ func (r *PostgresTagRepository) Delete(ctx context.Context, userID, tagID string
 	}
 	rows.Close()
 
-	// Delete all notes with this tag (CASCADE will handle note_tags)
-	if len(noteIDs) > 0 {
-		deleteNotesQuery := `DELETE FROM notes WHERE id = ANY($1) AND user_id = $2`
-		_, err = tx.Exec(ctx, deleteNotesQuery, noteIDs, userID)
+	switch mode {
+	case TagDeleteCascade:
+		// Move all notes with this tag to the trash
+		if len(noteIDs) > 0 {
+			trashNotesQuery := `UPDATE notes SET deleted_at = $1 WHERE id = ANY($2) AND user_id = $3`
+			_, err = tx.Exec(ctx, trashNotesQuery, time.Now(), noteIDs, userID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to trash notes: %w", err)
+			}
+		}
+
+	case TagDeleteReassign:
+		// Give the notes the target tag before this tag's links are removed
+		reassignQuery := `
+			INSERT INTO note_tags (note_id, tag_id, created_at)
+			SELECT nt.note_id, t.id, $3
+			FROM note_tags nt
+			INNER JOIN tags t ON t.id = $2 AND t.user_id = $4
+			WHERE nt.tag_id = $1
+			ON CONFLICT (note_id, tag_id) DO NOTHING
+		`
+		_, err = tx.Exec(ctx, reassignQuery, tagID, targetTagID, time.Now(), userID)
 		if err != nil {
-			return nil, fmt.Errorf("failed to delete notes: %w", err)
+			return nil, fmt.Errorf("failed to reassign notes: %w", err)
 		}
 	}
 
-	// Delete the tag
+	// Delete the tag (note_tags rows are removed by ON DELETE CASCADE)
 	deleteTagQuery := `DELETE FROM tags WHERE id = $1 AND user_id = $2`
 	result, err := tx.Exec(ctx, deleteTagQuery, tagID, userID)
 	if err != nil {
@@ -295,3 +582,233 @@ func (r *PostgresTagRepository) Delete(ctx context.Context, userID, tagID string
 
 	return noteIDs, nil
 }
+
+// Rename changes the tag's name and parent, renames its descendants to match, and records
+// a version for every note that carries one of the renamed tags
+func (r *PostgresTagRepository) Rename(ctx context.Context, userID, tagID, name string, parentID *string) (*Tag, error) {
+	// Start transaction
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var exists bool
+	existsQuery := `SELECT EXISTS(SELECT 1 FROM tags WHERE user_id = $1 AND name = $2 AND id <> $3)`
+	if err := tx.QueryRow(ctx, existsQuery, userID, name, tagID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to check tag name: %w", err)
+	}
+
+	if exists {
+		return nil, fmt.Errorf("tag %q already exists", name)
+	}
+
+	var oldName string
+	if err := tx.QueryRow(ctx, `SELECT name FROM tags WHERE id = $1 AND user_id = $2`, tagID, userID).Scan(&oldName); err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("tag not found")
+		}
+		return nil, fmt.Errorf("failed to find tag: %w", err)
+	}
+
+	tag := &Tag{}
+	updateQuery := `
+		UPDATE tags SET name = $1, parent_id = $2
+		WHERE id = $3 AND user_id = $4
+		RETURNING id, user_id, name, parent_id, color, icon, description, pinned, created_at
+	`
+	err = tx.QueryRow(ctx, updateQuery, name, parentID, tagID, userID).Scan(tagFields(tag)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rename tag: %w", err)
+	}
+
+	// Move descendants along: "old/child" becomes "new/child"
+	renamedTagIDs := []string{tagID}
+	descendantsQuery := `
+		UPDATE tags SET name = $1 || substr(name, length($2) + 1)
+		WHERE user_id = $3 AND left(name, length($2) + 1) = $2 || '/'
+		RETURNING id
+	`
+	rows, err := tx.Query(ctx, descendantsQuery, name, oldName, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rename child tags: %w", err)
+	}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan tag ID: %w", err)
+		}
+		renamedTagIDs = append(renamedTagIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to rename child tags: %w", err)
+	}
+
+	noteIDs, err := noteIDsWithTags(ctx, tx, userID, renamedTagIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := fmt.Sprintf("Tag renamed: %s → %s", oldName, name)
+	if err := recordTagVersions(ctx, tx, noteIDs, summary); err != nil {
+		return nil, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return tag, nil
+}
+
+// Merge moves the notes of the source tags to the target tag and deletes the source tags
+func (r *PostgresTagRepository) Merge(ctx context.Context, userID, targetTagID string, sourceTagIDs []string) ([]string, error) {
+	// Start transaction
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var targetName string
+	err = tx.QueryRow(ctx, `SELECT name FROM tags WHERE id = $1 AND user_id = $2`, targetTagID, userID).Scan(&targetName)
+	if err == pgx.ErrNoRows {
+		return nil, fmt.Errorf("target tag not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find tag: %w", err)
+	}
+
+	if err := ensureNoChildTags(ctx, tx, sourceTagIDs); err != nil {
+		return nil, err
+	}
+
+	noteIDs, err := noteIDsWithTags(ctx, tx, userID, sourceTagIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Give the notes the target tag; notes that already have it keep a single row
+	reassignQuery := `
+		INSERT INTO note_tags (note_id, tag_id, created_at)
+		SELECT DISTINCT nt.note_id, $2::text, $3::timestamp
+		FROM note_tags nt
+		WHERE nt.tag_id = ANY($1)
+		ON CONFLICT (note_id, tag_id) DO NOTHING
+	`
+	_, err = tx.Exec(ctx, reassignQuery, sourceTagIDs, targetTagID, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to reassign notes: %w", err)
+	}
+
+	// Delete the source tags (their note_tags rows are removed by ON DELETE CASCADE)
+	result, err := tx.Exec(ctx, `DELETE FROM tags WHERE id = ANY($1) AND user_id = $2`, sourceTagIDs, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete tags: %w", err)
+	}
+
+	if int(result.RowsAffected()) != len(sourceTagIDs) {
+		return nil, fmt.Errorf("one or more source tags not found")
+	}
+
+	summary := fmt.Sprintf("Tags merged into %s", targetName)
+	if err := recordTagVersions(ctx, tx, noteIDs, summary); err != nil {
+		return nil, err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return noteIDs, nil
+}
+
+// ensureNoChildTags fails if a tag outside tagIDs has one of them as its parent. Deleting the
+// parent would otherwise turn the child into a root tag that still carries the old path.
+func ensureNoChildTags(ctx context.Context, tx pgx.Tx, tagIDs []string) error {
+	var hasChildren bool
+	query := `SELECT EXISTS (SELECT 1 FROM tags WHERE parent_id = ANY($1) AND NOT (id = ANY($1)))`
+	if err := tx.QueryRow(ctx, query, tagIDs).Scan(&hasChildren); err != nil {
+		return fmt.Errorf("failed to check child tags: %w", err)
+	}
+
+	if hasChildren {
+		return fmt.Errorf("tag has child tags: rename, merge or delete them first")
+	}
+
+	return nil
+}
+
+// noteIDsWithTags returns the IDs of the user's notes that carry any of the tags
+func noteIDsWithTags(ctx context.Context, tx pgx.Tx, userID string, tagIDs []string) ([]string, error) {
+	query := `
+		SELECT DISTINCT n.id
+		FROM notes n
+		INNER JOIN note_tags nt ON n.id = nt.note_id
+		WHERE n.user_id = $1 AND nt.tag_id = ANY($2)
+	`
+	rows, err := tx.Query(ctx, query, userID, tagIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notes with tag: %w", err)
+	}
+	defer rows.Close()
+
+	noteIDs := []string{}
+	for rows.Next() {
+		var noteID string
+		if err := rows.Scan(&noteID); err != nil {
+			return nil, fmt.Errorf("failed to scan note ID: %w", err)
+		}
+		noteIDs = append(noteIDs, noteID)
+	}
+
+	return noteIDs, rows.Err()
+}
+
+// recordTagVersions snapshots the notes with their current tags, like the
+// note_update_version trigger does for title and content changes
+func recordTagVersions(ctx context.Context, tx pgx.Tx, noteIDs []string, summary string) error {
+	if len(noteIDs) == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO note_versions (
+			id, note_id, version_number, title, content_md, source_url, tags,
+			change_summary, chars_added, chars_removed, created_by, created_at
+		)
+		SELECT
+			gen_random_uuid()::text,
+			n.id,
+			COALESCE((SELECT MAX(v.version_number) FROM note_versions v WHERE v.note_id = n.id), 0) + 1,
+			n.title,
+			n.content_md,
+			n.source_url,
+			COALESCE(
+				ARRAY(
+					SELECT t.name
+					FROM note_tags nt
+					JOIN tags t ON nt.tag_id = t.id
+					WHERE nt.note_id = n.id
+					ORDER BY t.name
+				),
+				'{}'::text[]
+			),
+			$2,
+			0,
+			0,
+			n.user_id,
+			NOW()
+		FROM notes n
+		WHERE n.id = ANY($1)
+	`
+	if _, err := tx.Exec(ctx, query, noteIDs, summary); err != nil {
+		return fmt.Errorf("failed to record note versions: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package notes
+
+import "strings"
+
+// TagNameSimilarity scores how alike two tag names are, from 0 (nothing in common) to 1 (equal).
+// Only tags with the same parent are compared, so "frontend/testing" and "backend/testing" score 0.
+// Their last path segments are compared by edit distance, ignoring case and the separators
+// "-", "_", "." and spaces, so "go-lang" and "golang" are equal.
+func TagNameSimilarity(a, b string) float64 {
+	if tagParent(a) != tagParent(b) {
+		return 0
+	}
+
+	a = normalizeTagLeaf(a)
+	b = normalizeTagLeaf(b)
+
+	if a == b {
+		return 1
+	}
+
+	longest := max(len([]rune(a)), len([]rune(b)))
+	if longest == 0 {
+		return 0
+	}
+
+	return 1 - float64(levenshtein(a, b))/float64(longest)
+}
+
+// tagParent returns the path of a hierarchical tag name without its last segment
+func tagParent(name string) string {
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		return name[:i]
+	}
+	return ""
+}
+
+// normalizeTagLeaf returns the last segment of a tag name in lower case without separators
+func normalizeTagLeaf(name string) string {
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.NewReplacer("-", "", "_", "", ".", "", " ", "").Replace(strings.ToLower(name))
+}
+
+// levenshtein returns the number of single-rune edits needed to turn a into b
+func levenshtein(a, b string) int {
+	ra := []rune(a)
+	rb := []rune(b)
+
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(rb)]
+}
@@ -42,6 +42,18 @@ type ListVersionsResponse struct {
 	CurrentVersion int           `json:"current_version"`
 }
 
+// ConflictError is returned when a note is updated from a version that is no longer the latest
+type ConflictError struct {
+	BaseVersion    int          // Version the client edited (from If-Match)
+	CurrentVersion int          // Latest version on the server
+	Current        *Note        // The note as it is now
+	Diff           *VersionDiff // Changes between BaseVersion and CurrentVersion; nil if BaseVersion does not exist
+}
+
+func (e *ConflictError) Error() string {
+	return "note was changed since it was loaded"
+}
+
 // RestoreVersionRequest is the request to restore a version
 type RestoreVersionRequest struct {
 	VersionID string `json:"version_id"`
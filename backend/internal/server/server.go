@@ -1,6 +1,8 @@
 package server
 
 import (
+	"net"
+
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/muhammedikinci/yapgan/internal/auth"
@@ -13,9 +15,29 @@ type Server struct {
 	allowedOrigins []string
 }
 
-func New(allowedOrigins []string) *Server {
+func New(allowedOrigins []string, trustedProxies []string) *Server {
 	e := echo.New()
 
+	// Client IPs drive login lockouts and registration throttling, so forwarding
+	// headers are only believed when the request comes from a trusted proxy
+	if len(trustedProxies) == 0 {
+		e.IPExtractor = echo.ExtractIPDirect()
+	} else {
+		options := []echo.TrustOption{
+			echo.TrustLoopback(false),
+			echo.TrustLinkLocal(false),
+			echo.TrustPrivateNet(false),
+		}
+		for _, cidr := range trustedProxies {
+			_, ipRange, err := net.ParseCIDR(cidr)
+			if err != nil {
+				continue // Rejected by config validation
+			}
+			options = append(options, echo.TrustIPRange(ipRange))
+		}
+		e.IPExtractor = echo.ExtractIPFromXFFHeader(options...)
+	}
+
 	// Middleware
 	e.Use(middleware.Logger())
 	e.Use(middleware.Recover())
@@ -26,7 +48,9 @@ func New(allowedOrigins []string) *Server {
 			echo.HeaderContentType,
 			echo.HeaderAccept,
 			echo.HeaderAuthorization,
+			"If-Match",
 		},
+		ExposeHeaders: []string{"ETag"},
 	}))
 
 	return &Server{
@@ -55,68 +79,127 @@ func (s *Server) RegisterRoutes(
 	// Auth routes (public)
 	s.echo.POST("/api/auth/register", authHandler.Register)
 	s.echo.POST("/api/auth/login", authHandler.Login)
+	s.echo.POST("/api/auth/refresh", authHandler.Refresh)
+	s.echo.POST("/api/auth/password/forgot", authHandler.ForgotPassword)
+	s.echo.POST("/api/auth/password/reset", authHandler.ResetPassword)
+	s.echo.POST("/api/auth/email/verify", authHandler.VerifyEmail)
+	s.echo.POST("/api/auth/2fa/verify", authHandler.VerifyTwoFactor)
+	s.echo.GET("/api/auth/oidc/providers", authHandler.ListOIDCProviders)
+	s.echo.GET("/.well-known/jwks.json", authHandler.JWKS)
+	s.echo.POST("/api/auth/oidc/:provider/start", authHandler.StartOIDCLogin)
+	s.echo.POST("/api/auth/oidc/:provider/callback", authHandler.OIDCCallback)
 
 	// Protected routes
 	api := s.echo.Group("/api")
 	api.Use(auth.JWTMiddleware(authService))
 
-	// User info
-	api.GET("/me", func(c echo.Context) error {
-		userID := c.Get("user_id").(string)
-		return c.JSON(200, map[string]string{
-			"user_id": userID,
-			"message": "This is a protected route",
-		})
-	})
+	// Scopes required when a personal access token is used
+	notesRead := auth.RequireScope(auth.ScopeNotesRead)
+	notesWrite := auth.RequireScope(auth.ScopeNotesWrite)
+	chatScope := auth.RequireScope(auth.ScopeChat)
+	searchScope := auth.RequireScope(auth.ScopeSearch)
+	sessionOnly := auth.SessionOnly()
+	verified := auth.RequireVerifiedEmail(authService)
+
+	// Session routes
+	api.POST("/auth/logout", authHandler.Logout, sessionOnly)
+	api.POST("/auth/password/change", authHandler.ChangePassword, sessionOnly)
+	api.POST("/auth/email/resend", authHandler.ResendVerificationEmail, sessionOnly)
+	api.POST("/auth/2fa/enroll", authHandler.EnrollTOTP, sessionOnly)
+	api.POST("/auth/2fa/confirm", authHandler.ConfirmTOTP, sessionOnly)
+	api.POST("/auth/2fa/disable", authHandler.DisableTOTP, sessionOnly)
+	api.GET("/sessions", authHandler.ListSessions, sessionOnly)
+	api.DELETE("/sessions", authHandler.RevokeAllSessions, sessionOnly)
+	api.DELETE("/sessions/:id", authHandler.RevokeSession, sessionOnly)
+	api.GET("/security/events", authHandler.ListSecurityEvents, sessionOnly)
+
+	// Personal access token routes
+	api.POST("/tokens", authHandler.CreateAccessToken, sessionOnly)
+	api.GET("/tokens", authHandler.ListAccessTokens, sessionOnly)
+	api.DELETE("/tokens/:id", authHandler.RevokeAccessToken, sessionOnly)
+
+	// Profile and preferences
+	api.GET("/me", authHandler.GetProfile)
+	api.PATCH("/me", authHandler.UpdateProfile, sessionOnly)
+	api.DELETE("/me", authHandler.DeleteAccount, sessionOnly)
+	api.GET("/me/preferences", authHandler.GetPreferences)
+	api.PATCH("/me/preferences", authHandler.UpdatePreferences, sessionOnly)
+
+	// Admin routes
+	admin := api.Group("/admin", sessionOnly, auth.RequireAdmin())
+	admin.GET("/users", authHandler.ListUsers)
+	admin.POST("/users/:id/disable", authHandler.DisableUser)
+	admin.POST("/users/:id/enable", authHandler.EnableUser)
+	admin.POST("/users/:id/logout", authHandler.ForceLogout)
+	admin.PUT("/users/:id/role", authHandler.SetUserRole)
+	admin.POST("/invites", authHandler.CreateInvite)
+	admin.GET("/invites", authHandler.ListInvites)
+	admin.DELETE("/invites/:id", authHandler.DeleteInvite)
 
 	// Notes routes with action-specific
 	api.POST(
 		"/notes",
 		notesHandler.CreateNote,
+		notesWrite,
+		verified,
 	)
-	api.GET("/notes", notesHandler.ListNotes)
-	api.GET("/notes/:id", notesHandler.GetNote)
+	api.GET("/notes", notesHandler.ListNotes, notesRead)
+	api.POST("/notes/query", notesHandler.QueryNotes, notesRead)
+	api.GET("/notes/:id", notesHandler.GetNote, notesRead)
 	api.PUT(
 		"/notes/:id",
 		notesHandler.UpdateNote,
+		notesWrite,
+		verified,
 	)
-	api.DELETE("/notes/:id", notesHandler.DeleteNote)
-	api.GET("/notes/:id/backlinks", notesHandler.GetBacklinks)
-	api.POST("/notes/:id/share", notesHandler.ShareNote) // Toggle public sharing
+	api.DELETE("/notes/:id", notesHandler.DeleteNote, notesWrite, verified)
+	api.GET("/notes/:id/backlinks", notesHandler.GetBacklinks, notesRead)
+	api.POST("/notes/:id/share", notesHandler.ShareNote, notesWrite, verified) // Toggle public sharing
 
 	// Version history routes
-	api.GET("/notes/:id/versions", notesHandler.ListVersions)
-	api.GET("/notes/:id/versions/:v1/diff/:v2", notesHandler.GetVersionDiff)
-	api.POST("/notes/:id/restore", notesHandler.RestoreVersion)
+	api.GET("/notes/:id/versions", notesHandler.ListVersions, notesRead)
+	api.GET("/notes/:id/versions/:v1/diff/:v2", notesHandler.GetVersionDiff, notesRead)
+	api.POST("/notes/:id/restore", notesHandler.RestoreVersion, notesWrite, verified)
+
+	// Trash routes
+	api.GET("/trash", notesHandler.ListTrash, notesRead)
+	api.POST("/trash/:id/restore", notesHandler.RestoreNote, notesWrite, verified)
+	api.DELETE("/trash/:id", notesHandler.DeleteNotePermanently, notesWrite, verified)
+	api.DELETE("/trash", notesHandler.EmptyTrash, notesWrite, verified)
 
 	// Tags routes
-	api.GET("/tags", notesHandler.ListTags)
-	api.DELETE("/tags/:id", notesHandler.DeleteTag)
+	api.GET("/tags", notesHandler.ListTags, notesRead)
+	api.GET("/tags/analytics", notesHandler.GetTagAnalytics, notesRead)
+	api.POST("/tags/merge", notesHandler.MergeTags, notesWrite, verified)
+	api.PATCH("/tags/:id", notesHandler.UpdateTag, notesWrite, verified)
+	api.DELETE("/tags/:id", notesHandler.DeleteTag, notesWrite, verified)
 
 	// Stats routes
-	api.GET("/stats", notesHandler.GetStats)
+	api.GET("/stats", notesHandler.GetStats, notesRead)
 
 	// Search routes
-	api.POST("/search", notesHandler.Search)
+	api.POST("/search", notesHandler.Search, searchScope)
 
 	// Vector space routes
-	api.GET("/vector-space", notesHandler.GetVectorSpace)
+	api.GET("/vector-space", notesHandler.GetVectorSpace, searchScope)
 
 	// Graph routes
-	api.GET("/graph", notesHandler.GetGraph)
+	api.GET("/graph", notesHandler.GetGraph, notesRead)
 
 	// Chat routes (AI Chat with single-note conversations)
-	api.POST("/chat/conversations", chatHandler.CreateConversation)
-	api.GET("/chat/conversations", chatHandler.ListConversations)
-	api.GET("/chat/conversations/:id", chatHandler.GetConversation)
-	api.DELETE("/chat/conversations/:id", chatHandler.DeleteConversation)
+	api.POST("/chat/conversations", chatHandler.CreateConversation, chatScope)
+	api.GET("/chat/conversations", chatHandler.ListConversations, chatScope)
+	api.GET("/chat/conversations/:id", chatHandler.GetConversation, chatScope)
+	api.DELETE("/chat/conversations/:id", chatHandler.DeleteConversation, chatScope)
 	api.POST(
 		"/chat/conversations/:id/messages",
 		chatHandler.SendMessage,
+		chatScope,
 	)
 	api.POST(
 		"/chat/conversations/:id/stream",
 		chatHandler.SendMessageStream,
+		chatScope,
 	)
 
 	// Public routes (no authentication required)
@@ -0,0 +1,16 @@
+-- Create refresh_tokens table for refresh token rotation
+-- Each login starts a token family; every refresh rotates to a new token in the same family
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id VARCHAR(255) PRIMARY KEY, -- JWT "jti" claim
+    user_id VARCHAR(255) NOT NULL,
+    family_id VARCHAR(255) NOT NULL,
+    replaced_by VARCHAR(255), -- Set when the token has been rotated
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Create indexes for better query performance
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens(user_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens(family_id);
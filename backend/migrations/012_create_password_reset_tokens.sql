@@ -0,0 +1,13 @@
+-- Create password_reset_tokens table for single-use, expiring password reset links
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+    id VARCHAR(255) PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    token_hash VARCHAR(64) UNIQUE NOT NULL, -- SHA-256 hex of the token sent by email
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Create index on user_id for better query performance
+CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user_id ON password_reset_tokens(user_id);
@@ -0,0 +1,22 @@
+-- Create user_totp table for TOTP two-factor authentication
+CREATE TABLE IF NOT EXISTS user_totp (
+    user_id VARCHAR(255) PRIMARY KEY,
+    secret VARCHAR(64) NOT NULL, -- Base32 TOTP secret
+    enabled_at TIMESTAMP, -- NULL while enrollment is pending confirmation
+    last_used_step BIGINT NOT NULL DEFAULT 0, -- Last accepted time step, prevents code replay
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Create recovery_codes table for one-time two-factor recovery codes
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id VARCHAR(255) PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    code_hash VARCHAR(64) NOT NULL, -- SHA-256 hex of the normalised code
+    used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Create index on user_id for better query performance
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user_id ON recovery_codes(user_id);
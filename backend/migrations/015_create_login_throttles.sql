@@ -0,0 +1,23 @@
+-- Create login_throttles table to count failed attempts per account, IP and 2FA challenge
+CREATE TABLE IF NOT EXISTS login_throttles (
+    throttle_key VARCHAR(320) PRIMARY KEY, -- e.g. "login:account:<email>", "login:ip:<ip>", "register:ip:<ip>"
+    failures INTEGER NOT NULL DEFAULT 0, -- Failures since the last lockout or reset
+    lockouts INTEGER NOT NULL DEFAULT 0, -- Consecutive lockouts, drives the exponential backoff
+    locked_until TIMESTAMP,
+    last_failure_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Create security_events table so users can see lockouts on their account
+CREATE TABLE IF NOT EXISTS security_events (
+    id VARCHAR(255) PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    event_type VARCHAR(50) NOT NULL, -- "login_locked" or "two_factor_locked"
+    ip_address VARCHAR(45) NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    locked_until TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Create index for listing a user's events newest first
+CREATE INDEX IF NOT EXISTS idx_security_events_user_id_created_at ON security_events(user_id, created_at DESC);
@@ -0,0 +1,25 @@
+-- Create user_identities table linking users to external OpenID Connect identities
+CREATE TABLE IF NOT EXISTS user_identities (
+    id VARCHAR(255) PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    provider VARCHAR(100) NOT NULL, -- Provider name from config, e.g. "company"
+    subject VARCHAR(255) NOT NULL, -- "sub" claim, stable per provider
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_login_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT user_identities_provider_subject_unique UNIQUE (provider, subject)
+);
+
+-- Create index on user_id for better query performance
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities(user_id);
+
+-- Create oidc_login_states table holding state, nonce and PKCE verifier between start and callback
+CREATE TABLE IF NOT EXISTS oidc_login_states (
+    state VARCHAR(64) PRIMARY KEY,
+    provider VARCHAR(100) NOT NULL,
+    nonce VARCHAR(64) NOT NULL,
+    code_verifier VARCHAR(128) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
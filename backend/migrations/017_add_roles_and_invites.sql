@@ -0,0 +1,17 @@
+-- Add role and disabled flag to users
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'user'; -- "user" or "admin"
+ALTER TABLE users ADD COLUMN IF NOT EXISTS disabled_at TIMESTAMP; -- Disabled accounts cannot log in
+
+-- Create invite_codes table for invite-only registration
+CREATE TABLE IF NOT EXISTS invite_codes (
+    id VARCHAR(255) PRIMARY KEY,
+    code_hash VARCHAR(64) NOT NULL UNIQUE, -- SHA-256 hex of the invite code
+    email VARCHAR(255), -- Optional: only this address may use the invite
+    created_by VARCHAR(255) NOT NULL,
+    used_by VARCHAR(255),
+    used_at TIMESTAMP,
+    expires_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (used_by) REFERENCES users(id) ON DELETE SET NULL
+);
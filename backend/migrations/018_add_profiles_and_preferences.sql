@@ -0,0 +1,16 @@
+-- Add profile fields to users
+ALTER TABLE users ADD COLUMN IF NOT EXISTS name VARCHAR(100);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS avatar_url VARCHAR(2048);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS timezone VARCHAR(64); -- IANA name, e.g. "Europe/Istanbul"
+ALTER TABLE users ADD COLUMN IF NOT EXISTS locale VARCHAR(35); -- BCP 47 tag, e.g. "tr-TR"
+
+-- Create user_preferences table; NULL columns fall back to the server configuration
+CREATE TABLE IF NOT EXISTS user_preferences (
+    user_id VARCHAR(255) PRIMARY KEY,
+    page_size INTEGER,
+    search_language VARCHAR(32), -- Postgres text search configuration, e.g. "english"
+    chat_model VARCHAR(100),
+    default_visibility VARCHAR(20), -- "private" or "public"
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
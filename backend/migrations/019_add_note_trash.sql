@@ -0,0 +1,5 @@
+-- Soft delete: trashed notes keep their versions, tags and links until purged
+ALTER TABLE notes ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+-- Create index for listing and purging the trash
+CREATE INDEX IF NOT EXISTS idx_notes_deleted_at ON notes(deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,39 @@
+-- Hierarchical tags: a tag named "lang/go/concurrency" has the parent "lang/go"
+ALTER TABLE tags ADD COLUMN IF NOT EXISTS parent_id VARCHAR(255);
+
+ALTER TABLE tags DROP CONSTRAINT IF EXISTS fk_tags_parent_id;
+ALTER TABLE tags ADD CONSTRAINT fk_tags_parent_id
+    FOREIGN KEY (parent_id) REFERENCES tags(id) ON DELETE SET NULL;
+
+-- Create index for child lookups
+CREATE INDEX IF NOT EXISTS idx_tags_parent_id ON tags(parent_id);
+
+-- Create the missing intermediate tags of existing slash-delimited names, one level per pass
+DO $$
+DECLARE
+    inserted INTEGER;
+BEGIN
+    LOOP
+        INSERT INTO tags (id, user_id, name, created_at)
+        SELECT gen_random_uuid()::text, p.user_id, p.name, NOW()
+        FROM (
+            SELECT DISTINCT user_id, regexp_replace(name, '/[^/]*$', '') AS name
+            FROM tags
+            WHERE name LIKE '%/%'
+        ) p
+        WHERE p.name <> ''
+        ON CONFLICT (user_id, name) DO NOTHING;
+
+        GET DIAGNOSTICS inserted = ROW_COUNT;
+        EXIT WHEN inserted = 0;
+    END LOOP;
+END $$;
+
+-- Link existing tags to their parents
+UPDATE tags c
+SET parent_id = p.id
+FROM tags p
+WHERE c.parent_id IS NULL
+  AND c.name LIKE '%/%'
+  AND p.user_id = c.user_id
+  AND p.name = regexp_replace(c.name, '/[^/]*$', '');
@@ -0,0 +1,7 @@
+-- Tag metadata shown in the tag list, the graph and the vector space
+ALTER TABLE tags ADD COLUMN IF NOT EXISTS color VARCHAR(7); -- Hex colour, e.g. "#3b82f6"
+ALTER TABLE tags ADD COLUMN IF NOT EXISTS icon VARCHAR(64);
+ALTER TABLE tags ADD COLUMN IF NOT EXISTS description VARCHAR(500);
+
+-- Pinned tags win when choosing the tag that colours a note
+ALTER TABLE tags ADD COLUMN IF NOT EXISTS pinned BOOLEAN NOT NULL DEFAULT FALSE;
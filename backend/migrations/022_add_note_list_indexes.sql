@@ -0,0 +1,4 @@
+-- Indexes for keyset pagination of note lists: (sort key, id) per user, both directions
+CREATE INDEX IF NOT EXISTS idx_notes_user_created_id ON notes(user_id, created_at, id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_notes_user_updated_id ON notes(user_id, updated_at, id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_notes_user_title_id ON notes(user_id, title, id) WHERE deleted_at IS NULL;
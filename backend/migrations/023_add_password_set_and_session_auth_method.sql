@@ -0,0 +1,18 @@
+-- Track whether the user has chosen a local password; accounts created by a single
+-- sign-on login have none until they set one through the password reset flow
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password_set BOOLEAN NOT NULL DEFAULT TRUE;
+
+-- Accounts created by a single sign-on login got a random password nobody knows: their
+-- identity was linked when the account was created and no password reset was used since
+UPDATE users u SET password_set = FALSE
+WHERE EXISTS (
+    SELECT 1 FROM user_identities i
+    WHERE i.user_id = u.id AND i.created_at < u.created_at + INTERVAL '1 minute'
+)
+AND NOT EXISTS (
+    SELECT 1 FROM password_reset_tokens p
+    WHERE p.user_id = u.id AND p.used_at IS NOT NULL
+);
+
+-- Record how each session was signed in: "password" or "oidc"
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS auth_method VARCHAR(20) NOT NULL DEFAULT 'password';
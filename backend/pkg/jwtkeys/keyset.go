@@ -0,0 +1,308 @@
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Supported signing algorithms
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// LegacyKeyID is the key used to verify tokens issued before key IDs were added
+const LegacyKeyID = "default"
+
+// minSecretLength is the minimum length of HS256 secrets
+const minSecretLength = 32
+
+// KeyConfig describes one key of a keyset
+type KeyConfig struct {
+	ID             string
+	Algorithm      string // AlgorithmHS256, AlgorithmRS256 or AlgorithmEdDSA
+	Secret         string // HS256 only
+	PrivateKeyFile string // PEM file; required for the active asymmetric key
+	PublicKeyFile  string // PEM file; enough for retired asymmetric keys
+}
+
+// Key is a loaded signing or verification key
+type Key struct {
+	ID         string
+	Algorithm  string
+	signingKey interface{} // nil when the key can only verify
+	verifyKey  interface{}
+}
+
+// JWK is the public part of an asymmetric key as published in a JWKS document
+type JWK struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKSet is a JSON Web Key Set document
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// Keyset signs tokens with its active key and verifies tokens signed by any of its keys.
+// It is safe for concurrent use and can be replaced in place when keys are rotated.
+type Keyset struct {
+	mu     sync.RWMutex
+	active *Key
+	keys   map[string]*Key
+}
+
+// LoadKey reads the key material described by cfg
+func LoadKey(cfg KeyConfig) (*Key, error) {
+	key := &Key{ID: cfg.ID, Algorithm: cfg.Algorithm}
+
+	switch cfg.Algorithm {
+	case AlgorithmHS256:
+		if len(cfg.Secret) < minSecretLength {
+			return nil, fmt.Errorf("key %s: secret must be at least %d characters", cfg.ID, minSecretLength)
+		}
+		key.signingKey = []byte(cfg.Secret)
+		key.verifyKey = []byte(cfg.Secret)
+
+	case AlgorithmRS256, AlgorithmEdDSA:
+		if cfg.PrivateKeyFile != "" {
+			private, err := readPrivateKey(cfg.PrivateKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("key %s: %w", cfg.ID, err)
+			}
+			key.signingKey = private
+			key.verifyKey = private.Public()
+		} else if cfg.PublicKeyFile != "" {
+			public, err := readPublicKey(cfg.PublicKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("key %s: %w", cfg.ID, err)
+			}
+			key.verifyKey = public
+		} else {
+			return nil, fmt.Errorf("key %s: private_key_file or public_key_file is required", cfg.ID)
+		}
+
+		if err := checkKeyType(key); err != nil {
+			return nil, fmt.Errorf("key %s: %w", cfg.ID, err)
+		}
+
+	default:
+		return nil, fmt.Errorf("key %s: unsupported algorithm %q", cfg.ID, cfg.Algorithm)
+	}
+
+	return key, nil
+}
+
+// New builds a keyset from the given keys; activeID selects the signing key
+func New(activeID string, configs []KeyConfig) (*Keyset, error) {
+	keys := make(map[string]*Key, len(configs))
+	for _, cfg := range configs {
+		if _, exists := keys[cfg.ID]; exists {
+			return nil, fmt.Errorf("duplicate key id %s", cfg.ID)
+		}
+
+		key, err := LoadKey(cfg)
+		if err != nil {
+			return nil, err
+		}
+		keys[cfg.ID] = key
+	}
+
+	active, ok := keys[activeID]
+	if !ok {
+		return nil, fmt.Errorf("active key %s is not in the keyset", activeID)
+	}
+
+	if active.signingKey == nil {
+		return nil, fmt.Errorf("active key %s has no private key", activeID)
+	}
+
+	return &Keyset{active: active, keys: keys}, nil
+}
+
+// Replace swaps in the keys of other, so new tokens are signed with its active key
+func (k *Keyset) Replace(other *Keyset) {
+	other.mu.RLock()
+	active, keys := other.active, other.keys
+	other.mu.RUnlock()
+
+	k.mu.Lock()
+	k.active = active
+	k.keys = keys
+	k.mu.Unlock()
+}
+
+// Sign signs the claims with the active key and sets the kid header
+func (k *Keyset) Sign(claims jwt.MapClaims) (string, error) {
+	k.mu.RLock()
+	active := k.active
+	k.mu.RUnlock()
+
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(active.Algorithm), claims)
+	token.Header["kid"] = active.ID
+
+	return token.SignedString(active.signingKey)
+}
+
+// Parse verifies the token against the key named by its kid header and returns its claims.
+// Tokens without a kid are verified with the legacy key, if the keyset has one.
+func (k *Keyset) Parse(tokenString string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			kid = LegacyKeyID
+		}
+
+		k.mu.RLock()
+		key, ok := k.keys[kid]
+		k.mu.RUnlock()
+
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+
+		// The algorithm is bound to the key, never taken from the token alone
+		if token.Method.Alg() != key.Algorithm {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+
+		return key.verifyKey, nil
+	}, jwt.WithValidMethods([]string{AlgorithmHS256, AlgorithmRS256, AlgorithmEdDSA}))
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, fmt.Errorf("invalid token")
+	}
+
+	return claims, nil
+}
+
+// JWKS returns the public keys of the asymmetric keys in the keyset.
+// HS256 keys are shared secrets and are never published.
+func (k *Keyset) JWKS() JWKSet {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range k.keys {
+		switch public := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kid: key.ID,
+				Kty: "RSA",
+				Alg: key.Algorithm,
+				Use: "sig",
+				N:   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kid: key.ID,
+				Kty: "OKP",
+				Alg: key.Algorithm,
+				Use: "sig",
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(public),
+			})
+		}
+	}
+
+	sort.Slice(set.Keys, func(i, j int) bool {
+		return set.Keys[i].Kid < set.Keys[j].Kid
+	})
+
+	return set
+}
+
+// checkKeyType makes sure the key material matches the configured algorithm
+func checkKeyType(key *Key) error {
+	switch key.verifyKey.(type) {
+	case *rsa.PublicKey:
+		if key.Algorithm != AlgorithmRS256 {
+			return fmt.Errorf("RSA key cannot be used with %s", key.Algorithm)
+		}
+	case ed25519.PublicKey:
+		if key.Algorithm != AlgorithmEdDSA {
+			return fmt.Errorf("Ed25519 key cannot be used with %s", key.Algorithm)
+		}
+	default:
+		return fmt.Errorf("unsupported key type %T", key.verifyKey)
+	}
+
+	return nil
+}
+
+// readPrivateKey parses a PKCS#8 or PKCS#1 PEM private key
+func readPrivateKey(path string) (crypto.Signer, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", parsed)
+	}
+
+	return signer, nil
+}
+
+// readPublicKey parses a PKIX PEM public key
+func readPublicKey(path string) (crypto.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %w", err)
+	}
+
+	return key, nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data in %s", path)
+	}
+
+	return block, nil
+}
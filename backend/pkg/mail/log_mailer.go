@@ -0,0 +1,49 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// LogMailer writes emails to the log, or to .eml files when an output directory is set.
+// Use it for development and offline setups without a mail server.
+type LogMailer struct {
+	outputDir string
+	from      string
+}
+
+// NewLogMailer creates a new log mailer
+func NewLogMailer(outputDir, from string) *LogMailer {
+	return &LogMailer{
+		outputDir: outputDir,
+		from:      from,
+	}
+}
+
+// Send logs or stores the email instead of delivering it
+func (m *LogMailer) Send(ctx context.Context, to, subject, body string) error {
+	if m.outputDir == "" {
+		log.Printf("Email to %s\nSubject: %s\n\n%s", to, subject, body)
+		return nil
+	}
+
+	if err := os.MkdirAll(m.outputDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create mail output directory: %w", err)
+	}
+
+	// Keep file names filesystem-safe
+	safeTo := regexp.MustCompile(`[^a-zA-Z0-9@._-]+`).ReplaceAllString(to, "_")
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102-150405.000000000"), safeTo)
+
+	msg := buildMessage(m.from, to, subject, body)
+	if err := os.WriteFile(filepath.Join(m.outputDir, name), msg, 0o644); err != nil {
+		return fmt.Errorf("failed to write email: %w", err)
+	}
+
+	return nil
+}
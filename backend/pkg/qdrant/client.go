@@ -136,6 +136,10 @@ func (c *Client) SearchWithFilter(ctx context.Context, vector []float32, userID
 				},
 			},
 		},
+		// Notes in the trash keep their points but are hidden
+		MustNot: []*qdrant.Condition{
+			qdrant.NewMatchBool("trashed", true),
+		},
 	}
 
 	results, err := c.client.Query(ctx, &qdrant.QueryPoints{
@@ -169,6 +173,10 @@ func (c *Client) GetAllUserPoints(ctx context.Context, userID string, limit uint
 				},
 			},
 		},
+		// Notes in the trash keep their points but are hidden
+		MustNot: []*qdrant.Condition{
+			qdrant.NewMatchBool("trashed", true),
+		},
 	}
 
 	limitU32 := uint32(limit)
@@ -186,6 +194,107 @@ func (c *Client) GetAllUserPoints(ctx context.Context, userID string, limit uint
 	return scrollResult, nil
 }
 
+// CountUserPoints returns the exact number of points that belong to a user
+func (c *Client) CountUserPoints(ctx context.Context, userID string) (uint64, error) {
+	exact := true
+	count, err := c.client.Count(ctx, &qdrant.CountPoints{
+		CollectionName: c.collectionName,
+		Filter:         userFilter(userID),
+		Exact:          &exact,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count points: %w", err)
+	}
+
+	return count, nil
+}
+
+// DeleteUserPoints deletes every point whose payload user_id matches, and returns
+// how many points there were
+func (c *Client) DeleteUserPoints(ctx context.Context, userID string) (uint64, error) {
+	count, err := c.CountUserPoints(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	wait := true
+	_, err = c.client.Delete(ctx, &qdrant.DeletePoints{
+		CollectionName: c.collectionName,
+		Wait:           &wait,
+		Points:         qdrant.NewPointsSelectorFilter(userFilter(userID)),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete user points: %w", err)
+	}
+
+	return count, nil
+}
+
+// userFilter matches points whose payload user_id equals userID
+func userFilter(userID string) *qdrant.Filter {
+	return &qdrant.Filter{
+		Must: []*qdrant.Condition{
+			{
+				ConditionOneOf: &qdrant.Condition_Field{
+					Field: &qdrant.FieldCondition{
+						Key: "user_id",
+						Match: &qdrant.Match{
+							MatchValue: &qdrant.Match_Keyword{
+								Keyword: userID,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// SetNoteTrashed marks the points of a note as trashed or restored. Trashed points
+// are excluded from SearchWithFilter and GetAllUserPoints.
+func (c *Client) SetNoteTrashed(ctx context.Context, noteID string, trashed bool) error {
+	wait := true
+	_, err := c.client.SetPayload(ctx, &qdrant.SetPayloadPoints{
+		CollectionName: c.collectionName,
+		Wait:           &wait,
+		Payload:        qdrant.NewValueMap(map[string]interface{}{"trashed": trashed}),
+		PointsSelector: qdrant.NewPointsSelectorFilter(&qdrant.Filter{
+			Must: []*qdrant.Condition{
+				qdrant.NewMatch("note_id", noteID),
+			},
+		}),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update point payload: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteNoteChunks deletes the points of a note from chunk index from on, including points
+// stored without a chunk index. Use from = 0 to delete all points of the note.
+func (c *Client) DeleteNoteChunks(ctx context.Context, noteID string, from int) error {
+	keepBelow := float64(from)
+	wait := true
+	_, err := c.client.Delete(ctx, &qdrant.DeletePoints{
+		CollectionName: c.collectionName,
+		Wait:           &wait,
+		Points: qdrant.NewPointsSelectorFilter(&qdrant.Filter{
+			Must: []*qdrant.Condition{
+				qdrant.NewMatch("note_id", noteID),
+			},
+			MustNot: []*qdrant.Condition{
+				qdrant.NewRange("chunk_index", &qdrant.Range{Lt: &keepBelow}),
+			},
+		}),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete note chunks: %w", err)
+	}
+
+	return nil
+}
+
 // hashID converts a string ID to a numeric ID for Qdrant
 // Simple hash function for demo purposes
 func hashID(id string) uint64 {
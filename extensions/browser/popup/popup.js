@@ -7,7 +7,7 @@ const DRAFT_NOTE_KEY = "yapgan_draft_note";
 
 // DOM Elements
 let loginScreen, registerScreen, saveScreen, loginForm, registerForm, saveForm;
-let emailInput, passwordInput, apiUrlInput;
+let emailInput, passwordInput, accessTokenInput, apiUrlInput;
 let registerEmailInput,
   registerPasswordInput,
   registerPasswordConfirmInput,
@@ -34,6 +34,7 @@ document.addEventListener("DOMContentLoaded", async () => {
 
   emailInput = document.getElementById("email");
   passwordInput = document.getElementById("password");
+  accessTokenInput = document.getElementById("access-token");
   apiUrlInput = document.getElementById("api-url");
 
   registerEmailInput = document.getElementById("register-email");
@@ -251,8 +252,15 @@ async function handleLogin(e) {
 
   const email = emailInput.value.trim();
   const password = passwordInput.value;
+  const accessToken = accessTokenInput.value.trim();
   const apiUrl = apiUrlInput.value.trim();
 
+  // Personal access tokens don't expire with the session
+  if (accessToken) {
+    await handleAccessTokenLogin(apiUrl, accessToken);
+    return;
+  }
+
   if (!email || !password) {
     showError(loginError, "Email and password are required");
     return;
@@ -276,6 +284,13 @@ async function handleLogin(e) {
       throw new Error(data.message || "Login failed");
     }
 
+    // Accounts with two-factor authentication need a personal access token
+    if (data.two_factor_required) {
+      throw new Error(
+        "This account uses two-factor authentication. Log in with a personal access token instead.",
+      );
+    }
+
     // Save credentials
     await setStorageItem(AUTH_TOKEN_KEY, data.access_token);
     await setStorageItem(API_CONFIG_KEY, apiUrl);
@@ -291,6 +306,31 @@ async function handleLogin(e) {
   }
 }
 
+async function handleAccessTokenLogin(apiUrl, accessToken) {
+  setLoading(loginBtn, true);
+  hideError(loginError);
+
+  try {
+    const isValid = await validateToken(apiUrl, accessToken);
+
+    if (!isValid) {
+      throw new Error("Invalid access token or missing notes:read scope");
+    }
+
+    // Save credentials
+    await setStorageItem(AUTH_TOKEN_KEY, accessToken);
+    await setStorageItem(API_CONFIG_KEY, apiUrl);
+
+    // Show save screen
+    showSaveScreen();
+    await loadCurrentPageData();
+  } catch (error) {
+    showError(loginError, error.message);
+  } finally {
+    setLoading(loginBtn, false);
+  }
+}
+
 async function handleRegister(e) {
   e.preventDefault();
 